@@ -0,0 +1,375 @@
+package main
+
+import (
+	"github.com/hexaflex/wireworld-gpu/math"
+)
+
+// registerActions creates the action map with all application actions and
+// binds the inputs from the configuration.
+//
+// Every action listed by defaultKeys must be registered here.
+func (a *Application) registerActions() error {
+	a.actions = NewActionMap()
+
+	for _, act := range []*Action{
+		{
+			Name:        "quit",
+			Description: "Close the program.",
+			Press:       func() { a.window.SetShouldClose(true) },
+		},
+		{
+			Name:        "save-state",
+			Description: "Save the current simulation state.",
+			Press:       a.saveState,
+		},
+		{
+			Name:        "load-state",
+			Description: "Load the latest saved simulation state.",
+			Press:       a.loadState,
+		},
+		{
+			Name:        "toggle-recording",
+			Description: "Start/Stop recording an animation.",
+			Press:       a.toggleRecording,
+		},
+		{
+			Name:        "reload",
+			Description: "Reset the simulation by reloading the input file.",
+			Press:       a.reload,
+		},
+		{
+			Name:        "toggle-fullscreen",
+			Description: "Switch between windowed and fullscreen mode.",
+			Press: func() {
+				w, h := a.window.GetFramebufferSize()
+				a.setWindowMode(w, h, !a.config.Fullscreen)
+			},
+		},
+		{
+			Name:        "center",
+			Description: "Center the simulation in the window.",
+			Press: func() {
+				view := a.displaySize()
+				a.display.Center(math.Vec2{float32(view.X), float32(view.Y)})
+			},
+		},
+		{
+			Name:        "toggle-run",
+			Description: "Start/Stop the simulation.",
+			Press:       func() { a.running = !a.running },
+		},
+		{
+			Name:        "step",
+			Description: "Perform a single simulation step.",
+			Press:       func() { a.step(1) },
+		},
+		{
+			Name:        "step-back",
+			Description: "Go back to the previous generation.",
+			Press:       func() { a.stepBack(1) },
+		},
+		{
+			Name:        "rewind",
+			Description: "Go back several generations.",
+			Press:       func() { a.stepBack(a.config.StepBack) },
+		},
+		{
+			Name:        "speed-up",
+			Description: "Increase the simulation speed by 10x.",
+			Press:       a.increaseClockspeed,
+		},
+		{
+			Name:        "speed-down",
+			Description: "Decrease the simulation speed by 10x.",
+			Press:       a.decreaseClockspeed,
+		},
+		{
+			// Panning is handled in the cursor callback,
+			// for as long as this action is held.
+			Name:        "pan",
+			Description: "Pan the camera while held.",
+		},
+		{
+			Name:        "zoom-in",
+			Description: "Zoom in on the mouse cursor.",
+			Press: func() {
+				a.display.Zoom(a.scrollAmount, a.mouse)
+				a.updatePreview()
+			},
+		},
+		{
+			Name:        "zoom-out",
+			Description: "Zoom out from the mouse cursor.",
+			Press: func() {
+				a.display.Zoom(-a.scrollAmount, a.mouse)
+				a.updatePreview()
+			},
+		},
+		{
+			Name:        "toggle-edit",
+			Description: "Enable/Disable edit mode.",
+			Press: func() {
+				a.editor.Enabled = !a.editor.Enabled
+				a.editor.Drawing = false
+				a.updatePreview()
+			},
+		},
+		{
+			// Painting continues in the cursor callback,
+			// for as long as this action is held.
+			Name:        "paint",
+			Description: "Draw with the active tool while held, in edit mode.",
+			Press:       a.beginPaint,
+			Release:     a.endPaint,
+		},
+		{
+			Name:        "tool-brush",
+			Description: "Paint with the brush.",
+			Press:       func() { a.setTool(ToolBrush) },
+		},
+		{
+			Name:        "tool-line",
+			Description: "Draw lines.",
+			Press:       func() { a.setTool(ToolLine) },
+		},
+		{
+			Name:        "tool-rect",
+			Description: "Draw rectangle outlines.",
+			Press:       func() { a.setTool(ToolRect) },
+		},
+		{
+			Name:        "tool-filled-rect",
+			Description: "Draw filled rectangles.",
+			Press:       func() { a.setTool(ToolFilledRect) },
+		},
+		{
+			Name:        "tool-fill",
+			Description: "Flood fill connected cells of the same state.",
+			Press:       func() { a.setTool(ToolFill) },
+		},
+		{
+			Name:        "tool-select",
+			Description: "Select a rectangular region.",
+			Press:       func() { a.setTool(ToolSelect) },
+		},
+		{
+			Name:        "tool-probe",
+			Description: "Add or remove probes by clicking cells.",
+			Press:       func() { a.setTool(ToolProbe) },
+		},
+		{
+			Name:        "export-vcd",
+			Description: "Write the recorded probe states to a VCD file.",
+			Press:       a.exportVCD,
+		},
+		{
+			Name:        "toggle-waveform",
+			Description: "Show/Hide the waveform panel of the probes.",
+			Press:       a.toggleWaveform,
+		},
+		{
+			Name:        "tool-breakpoint",
+			Description: "Add breakpoints by dragging a region, or toggle them by clicking.",
+			Press:       func() { a.setTool(ToolBreakpoint) },
+		},
+		{
+			Name:        "tool-delay",
+			Description: "Measure the propagation delay between two clicked cells.",
+			Press:       func() { a.setTool(ToolDelay) },
+		},
+		{
+			Name:        "remove-breakpoint",
+			Description: "Remove the breakpoint under the mouse cursor.",
+			Press:       a.removeBreakpoint,
+		},
+		{
+			Name:        "list-breakpoints",
+			Description: "Write all breakpoints to the log.",
+			Press:       a.listBreakpoints,
+		},
+		{
+			Name:        "toggle-breakpoints",
+			Description: "Disable all breakpoints, or enable them if none is enabled.",
+			Press:       a.toggleBreakpoints,
+		},
+		{
+			Name:        "save-breakpoints",
+			Description: "Write the breakpoints to the breakpoint file of the input.",
+			Press:       a.saveBreakpoints,
+		},
+		{
+			Name:        "save-probes",
+			Description: "Write the probes to the probe file of the input.",
+			Press:       a.saveProbes,
+		},
+		{
+			Name:        "toggle-cycles",
+			Description: "Enable/Disable detection of repeating simulation states.",
+			Press:       a.toggleCycles,
+		},
+		{
+			Name:        "toggle-population",
+			Description: "Enable/Disable counting the cells in each state every generation.",
+			Press:       a.togglePopulation,
+		},
+		{
+			Name:        "export-population",
+			Description: "Write the recorded population counts to a CSV file.",
+			Press:       a.exportPopulation,
+		},
+		{
+			Name:        "toggle-heatmap",
+			Description: "Show/Hide the heatmap of electron activity.",
+			Press:       a.toggleHeatmap,
+		},
+		{
+			Name:        "export-heatmap",
+			Description: "Write the heatmap to a PNG file.",
+			Press:       a.exportHeatmap,
+		},
+		{
+			Name:        "clear-heatmap",
+			Description: "Start counting the heatmap anew.",
+			Press:       a.clearHeatmap,
+		},
+		{
+			Name:        "find-dead-wires",
+			Description: "Mark the wires which never carry an electron, or clear the marks.",
+			Press:       a.toggleDeadWires,
+		},
+		{
+			Name:        "lint",
+			Description: "Mark malformed electrons and other likely mistakes, or clear the marks.",
+			Press:       a.toggleLint,
+		},
+		{
+			Name:        "copy",
+			Description: "Copy the selected cells to the clipboard.",
+			Press:       a.copySelection,
+		},
+		{
+			Name:        "cut",
+			Description: "Copy the selected cells to the clipboard and clear them.",
+			Press:       a.cutSelection,
+		},
+		{
+			Name:        "paste",
+			Description: "Start pasting cells from the clipboard.",
+			Press:       a.paste,
+		},
+		{
+			Name:        "rotate",
+			Description: "Rotate the cells being pasted by 90 degrees.",
+			Press:       a.rotatePaste,
+		},
+		{
+			Name:        "mirror-horizontal",
+			Description: "Mirror the cells being pasted horizontally.",
+			Press:       func() { a.mirrorPaste(true) },
+		},
+		{
+			Name:        "mirror-vertical",
+			Description: "Mirror the cells being pasted vertically.",
+			Press:       func() { a.mirrorPaste(false) },
+		},
+		{
+			Name:        "cancel",
+			Description: "Stop pasting, or clear the selection.",
+			Press:       a.cancel,
+		},
+		{
+			Name:        "grow-canvas",
+			Description: "Add empty cells on all sides of the canvas.",
+			Press:       func() { a.growCanvas(true, true, true, true) },
+		},
+		{
+			Name:        "grow-left",
+			Description: "Add empty cells on the left side of the canvas.",
+			Press:       func() { a.growCanvas(true, false, false, false) },
+		},
+		{
+			Name:        "grow-right",
+			Description: "Add empty cells on the right side of the canvas.",
+			Press:       func() { a.growCanvas(false, false, true, false) },
+		},
+		{
+			Name:        "grow-up",
+			Description: "Add empty cells on the top side of the canvas.",
+			Press:       func() { a.growCanvas(false, true, false, false) },
+		},
+		{
+			Name:        "grow-down",
+			Description: "Add empty cells on the bottom side of the canvas.",
+			Press:       func() { a.growCanvas(false, false, false, true) },
+		},
+		{
+			Name:        "crop",
+			Description: "Crop the canvas to the selection.",
+			Press:       a.cropCanvas,
+		},
+		{
+			Name:        "trim",
+			Description: "Shrink the canvas to the non-empty cells, plus a margin.",
+			Press:       a.trimCanvas,
+		},
+		{
+			Name:        "toggle-library",
+			Description: "Show/Hide the component library.",
+			Press:       a.toggleLibrary,
+		},
+		{
+			Name:        "next-component",
+			Description: "Select the next component in the library.",
+			Press:       func() { a.selectComponent(1) },
+		},
+		{
+			Name:        "prev-component",
+			Description: "Select the previous component in the library.",
+			Press:       func() { a.selectComponent(-1) },
+		},
+		{
+			Name:        "undo",
+			Description: "Undo the last edit, reload or loaded state.",
+			Press:       a.undo,
+		},
+		{
+			Name:        "redo",
+			Description: "Redo the last undone change.",
+			Press:       a.redo,
+		},
+		{
+			Name:        "select-empty",
+			Description: "Paint empty cells.",
+			Press:       func() { a.setCell(CellEmpty) },
+		},
+		{
+			Name:        "select-wire",
+			Description: "Paint wire cells.",
+			Press:       func() { a.setCell(CellWire) },
+		},
+		{
+			Name:        "select-head",
+			Description: "Paint electron heads.",
+			Press:       func() { a.setCell(CellHead) },
+		},
+		{
+			Name:        "select-tail",
+			Description: "Paint electron tails.",
+			Press:       func() { a.setCell(CellTail) },
+		},
+		{
+			Name:        "brush-grow",
+			Description: "Increase the brush size.",
+			Press:       func() { a.setBrush(a.editor.Brush + 1) },
+		},
+		{
+			Name:        "brush-shrink",
+			Description: "Decrease the brush size.",
+			Press:       func() { a.setBrush(a.editor.Brush - 1) },
+		},
+	} {
+		a.actions.Register(act)
+	}
+
+	return a.actions.Load(a.config.Keys)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"image/png"
+	"log"
+	"os"
+)
+
+// setHeatmap shows or hides the heatmap. Showing it starts counting from
+// the current generation.
+func (a *Application) setHeatmap(enabled bool) {
+	a.simulation.SetHeatmap(nil)
+
+	if a.heatmap != nil {
+		a.heatmap.Release()
+		a.heatmap = nil
+	}
+
+	if enabled {
+		h, err := NewHeatmap(a.simulation.Size(), a.config.HeatWindow)
+		if err != nil {
+			log.Println("failed to create heatmap:", err)
+		} else {
+			a.heatmap = h
+			a.simulation.SetHeatmap(h)
+		}
+	}
+
+	if a.heatmap == nil {
+		a.display.SetHeatScale(0)
+	}
+}
+
+// toggleHeatmap shows or hides the heatmap.
+func (a *Application) toggleHeatmap() {
+	a.setHeatmap(a.heatmap == nil)
+}
+
+// clearHeatmap starts counting anew from the current generation.
+func (a *Application) clearHeatmap() {
+	if a.heatmap != nil {
+		a.heatmap.Clear()
+	}
+}
+
+// exportHeatmap writes the heatmap, drawn over the circuit, to a PNG file.
+// This waits for the GPU.
+func (a *Application) exportHeatmap() {
+	if a.heatmap == nil {
+		log.Println("the heatmap is not shown")
+		return
+	}
+
+	g := a.simulation.Cells(a.simulation.Bounds())
+	img := heatImage(g, a.heatmap.Rates(), &a.config.Palette)
+	file := a.outputFile("heat.png")
+
+	go func() {
+		log.Println("saving heatmap to", file)
+
+		fd, err := os.Create(file)
+		if err != nil {
+			log.Println("failed to create heatmap file:", err)
+			return
+		}
+
+		if err = png.Encode(fd, img); err != nil {
+			log.Println("failed to write heatmap file:", err)
+			fd.Close()
+			return
+		}
+
+		if err = fd.Close(); err != nil {
+			log.Println("failed to save heatmap file:", err)
+		}
+	}()
+}
+
+// heatmapChanged keeps the heatmap in step with a replaced or resized
+// simulation. The counts start over.
+func (a *Application) heatmapChanged() {
+	if a.heatmap == nil {
+		return
+	}
+
+	if a.heatmap.Size() != a.simulation.Size() {
+		a.setHeatmap(true)
+		return
+	}
+
+	a.heatmap.Clear()
+	a.simulation.SetHeatmap(a.heatmap)
+}
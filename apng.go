@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io"
+	"time"
+)
+
+// pngSignature is the magic header of every PNG file.
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+// pngChunk defines a single chunk in a PNG stream.
+type pngChunk struct {
+	Type string
+	Data []byte
+}
+
+// encodeAPNG writes the given frames as an animated PNG which loops forever.
+// Each frame is shown for the given delay. All frames must have the same
+// dimensions and palette.
+//
+// The standard library has no APNG support, so each frame is encoded as a
+// regular PNG and its image data is then repackaged into frame chunks.
+func encodeAPNG(w io.Writer, frames []*image.Paletted, delay time.Duration) error {
+	if len(frames) == 0 {
+		return errors.New("apng: no frames to encode")
+	}
+
+	enc := png.Encoder{CompressionLevel: png.BestCompression}
+	bounds := frames[0].Bounds()
+	var seq uint32
+	var out []pngChunk
+
+	for i, frame := range frames {
+		if frame.Bounds() != bounds {
+			return errors.New("apng: frames differ in size")
+		}
+
+		var buf bytes.Buffer
+		if err := enc.Encode(&buf, frame); err != nil {
+			return err
+		}
+
+		chunks, err := readPNGChunks(&buf)
+		if err != nil {
+			return err
+		}
+
+		if i == 0 {
+			// Copy everything up to the image data from the first frame
+			// and announce the animation right after the header.
+			for _, c := range chunks {
+				if c.Type == "IDAT" || c.Type == "IEND" {
+					break
+				}
+
+				out = append(out, c)
+				if c.Type == "IHDR" {
+					out = append(out, pngChunk{"acTL", be32(uint32(len(frames)), 0)})
+				}
+			}
+		}
+
+		out = append(out, pngChunk{"fcTL", frameControl(seq, bounds, delay)})
+		seq++
+
+		for _, c := range chunks {
+			if c.Type != "IDAT" {
+				continue
+			}
+
+			if i == 0 {
+				out = append(out, c)
+				continue
+			}
+
+			out = append(out, pngChunk{"fdAT", append(be32(seq), c.Data...)})
+			seq++
+		}
+	}
+
+	out = append(out, pngChunk{"IEND", nil})
+
+	if _, err := io.WriteString(w, pngSignature); err != nil {
+		return err
+	}
+
+	for _, c := range out {
+		if err := writePNGChunk(w, c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// frameControl returns the contents of an fcTL chunk for a frame covering
+// the entire image.
+func frameControl(seq uint32, bounds image.Rectangle, delay time.Duration) []byte {
+	data := be32(seq, uint32(bounds.Dx()), uint32(bounds.Dy()), 0, 0)
+
+	// Delay is stored as a fraction; we use milliseconds.
+	var tail [6]byte
+	binary.BigEndian.PutUint16(tail[0:], uint16(delay/time.Millisecond))
+	binary.BigEndian.PutUint16(tail[2:], 1000)
+	tail[4] = 0 // dispose_op: none
+	tail[5] = 0 // blend_op: source
+	return append(data, tail[:]...)
+}
+
+// readPNGChunks splits a PNG stream into its chunks.
+func readPNGChunks(r io.Reader) ([]pngChunk, error) {
+	var sig [len(pngSignature)]byte
+	if _, err := io.ReadFull(r, sig[:]); err != nil {
+		return nil, err
+	}
+
+	if string(sig[:]) != pngSignature {
+		return nil, errors.New("png: invalid signature")
+	}
+
+	var out []pngChunk
+	for {
+		var hdr [8]byte
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			return nil, err
+		}
+
+		data := make([]byte, binary.BigEndian.Uint32(hdr[:4]))
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+
+		var crc [4]byte
+		if _, err := io.ReadFull(r, crc[:]); err != nil {
+			return nil, err
+		}
+
+		c := pngChunk{string(hdr[4:]), data}
+		out = append(out, c)
+
+		if c.Type == "IEND" {
+			return out, nil
+		}
+	}
+}
+
+// writePNGChunk writes c, along with its length and checksum.
+func writePNGChunk(w io.Writer, c pngChunk) error {
+	hdr := append(be32(uint32(len(c.Data))), c.Type...)
+
+	crc := crc32.NewIEEE()
+	crc.Write(hdr[4:])
+	crc.Write(c.Data)
+
+	for _, b := range [][]byte{hdr, c.Data, be32(crc.Sum32())} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// be32 returns the given values as consecutive big endian integers.
+func be32(v ...uint32) []byte {
+	out := make([]byte, 4*len(v))
+	for i, x := range v {
+		binary.BigEndian.PutUint32(out[i*4:], x)
+	}
+	return out
+}
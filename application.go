@@ -1,9 +1,12 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"image/png"
+	"image"
+	"image/color"
 	"log"
+	gomath "math"
 	"os"
 	"path/filepath"
 	"sort"
@@ -17,17 +20,50 @@ import (
 	"github.com/pkg/errors"
 )
 
+// pendingRead defines an asynchronous readback of simulation state,
+// along with the function to call once its data is available.
+type pendingRead struct {
+	readback *Readback
+	done     func(pix []byte, size math.Vec2)
+}
+
 // Application defines application state.
 type Application struct {
 	config         *Config
+	actions        *ActionMap
 	window         *glfw.Window
 	simulation     *Simulation
 	display        *SimulationDisplay
+	overlay        *Overlay
+	recorder       *Recorder
+	editor         *Editor
+	clipboard      *Grid
+	history        *History
+	rewind         *Rewind
+	library        *Library
+	probes         *ProbeSampler
+	waveform       *Waveform
+	evaluator      *BreakpointEvaluator
+	breakpoints    []Breakpoint
+	cycles         *HashSampler
+	population     *PopulationSampler
+	heatmap        *Heatmap
+	deadWires      *WireReport
+	lint           []LintFinding
+	nets           *NetMap
+	netLabels      *NetLabels
+	net            int
+	delayStart     image.Point
+	delayPicked    bool
+	delayPath      []image.Point
+	regions        []Region
 	mouse          math.Vec2
 	mouseDelta     math.Vec2
+	scrollAmount   float32
 	titleUpdated   time.Time
 	stepTime       time.Time
 	stepInterval   time.Duration
+	pendingReads   []pendingRead
 	stepMultiplier int
 	clockCycles    uint64
 	uboShared      uint32
@@ -39,14 +75,24 @@ type Application struct {
 func (a *Application) Initialize() {
 	var err error
 
-	a.config = parseArgs()
-	a.stepInterval = time.Millisecond * 10
-	a.stepMultiplier = 1
+	a.config, err = parseArgs(os.Args[1:])
+	if err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(0)
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	a.setClockspeed(a.config.Speed)
+	a.editor = NewEditor()
+	a.history = NewHistory(a.config.History << 20)
 
 	log.Println(Version())
 	a.check(glfw.Init())
 	a.check(a.setWindowMode(a.config.Width, a.config.Height, a.config.Fullscreen))
 	a.check(gl.Init())
+	a.check(a.registerActions())
 	a.glInitialized = true
 
 	glver := gl.GoStr(gl.GetString(gl.VERSION))
@@ -68,7 +114,7 @@ func (a *Application) Initialize() {
 	gl.BindBufferRange(gl.UNIFORM_BUFFER, 0, a.uboShared, 0, structSize)
 	gl.BindBuffer(gl.UNIFORM_BUFFER, 0)
 
-	a.simulation, err = LoadSimulation(a.config.Input, &a.config.Palette)
+	sim, err := LoadSimulation(a.config.Input, &a.config.Palette)
 	a.check(err)
 
 	displayShader, err := DisplayShader.Compile()
@@ -77,8 +123,33 @@ func (a *Application) Initialize() {
 	w, h := a.window.GetFramebufferSize()
 
 	a.display = NewSimulationDisplay(displayShader)
-	a.display.SetSize(a.simulation.Size())
+
+	panelShader, err := PanelShader.Compile()
+	a.check(err)
+
+	// A missing or broken library is not fatal. The viewer just has
+	// no components to offer.
+	components, err := LoadLibrary(a.config.Library, &a.config.Palette)
+	if err != nil {
+		log.Println("failed to load component library:", err)
+	}
+	a.library = NewLibrary(components, panelShader, &a.config.Palette)
+
+	waveformShader, err := WaveformShader.Compile()
+	a.check(err)
+
+	a.waveform = NewWaveform(waveformShader, a.config.WaveformLength, a.config.WaveformLane)
+
+	a.overlay = NewOverlay(sim.Size())
+	a.setSimulation(sim)
+	a.loadProbes()
+	a.loadBreakpoints()
+	a.setCycleDetection(a.config.CycleDetect)
+	a.loadRegions()
+	a.setPopulation(a.config.Population)
+	a.setHeatmap(a.config.Heatmap)
 	a.display.SetPalette(&a.config.Palette)
+	a.display.SetZoom(a.config.Zoom)
 	a.display.Center(math.Vec2{float32(w), float32(h)})
 
 	// Force resize call now that components have been initialized.
@@ -89,11 +160,66 @@ func (a *Application) Initialize() {
 func (a *Application) Release() {
 	gl.DeleteBuffers(1, &a.uboShared)
 
+	for _, pr := range a.pendingReads {
+		pr.readback.Release()
+	}
+	a.pendingReads = nil
+
 	if a.simulation != nil {
 		a.simulation.Release()
 		a.simulation = nil
 	}
 
+	if a.rewind != nil {
+		a.rewind.Release()
+		a.rewind = nil
+	}
+
+	if a.probes != nil {
+		a.probes.Release()
+		a.probes = nil
+	}
+
+	if a.evaluator != nil {
+		a.evaluator.Release()
+		a.evaluator = nil
+	}
+
+	if a.cycles != nil {
+		a.cycles.Release()
+		a.cycles = nil
+	}
+
+	if a.population != nil {
+		a.population.Release()
+		a.population = nil
+	}
+
+	if a.heatmap != nil {
+		a.heatmap.Release()
+		a.heatmap = nil
+	}
+
+	if a.netLabels != nil {
+		a.netLabels.Release()
+		a.netLabels = nil
+	}
+
+	if a.overlay != nil {
+		a.overlay.Release()
+		a.overlay = nil
+	}
+
+	if a.library != nil {
+		a.library.Release()
+		a.library = nil
+	}
+
+	if a.waveform != nil {
+		a.waveform.Release()
+		a.waveform = nil
+	}
+
 	if a.display != nil {
 		a.display.Release()
 		a.display = nil
@@ -104,6 +230,7 @@ func (a *Application) Release() {
 		a.window.SetFramebufferSizeCallback(nil)
 		a.window.SetScrollCallback(nil)
 		a.window.SetCursorPosCallback(nil)
+		a.window.SetMouseButtonCallback(nil)
 		a.window.Destroy()
 		a.window = nil
 	}
@@ -121,19 +248,112 @@ func (a *Application) Update() {
 		if a.running {
 			state = "running"
 		}
+		if p := a.populationState(); p != "" {
+			state += ", " + p
+		}
+		if c := a.cycleState(); c != "" {
+			state += ", " + c
+		}
+		if a.recorder != nil {
+			state += fmt.Sprintf(", recording %d/%d", a.recorder.Len(), a.config.RecordFrames)
+		}
+		if a.editor.Enabled {
+			state += fmt.Sprintf(", editing %s with %s", cellName(a.editor.Cell), a.editor.Tool)
+			if a.editor.Tool == ToolBrush {
+				state += fmt.Sprintf(" %d", a.editor.Brush)
+			}
+			if c := a.editor.Component; c != nil {
+				state += fmt.Sprintf(", placing %s", c.Name)
+			} else if a.editor.Paste != nil {
+				state += fmt.Sprintf(", pasting %dx%d", a.editor.Paste.Width, a.editor.Paste.Height)
+			}
+		}
 		text := fmt.Sprintf(
-			"%s - [%s] clock: %s",
+			"%s - [%s] generation: %d, clock: %s",
 			Version(),
 			state,
+			a.simulation.Generation(),
 			a.clockFrequency(),
 		)
 		a.window.SetTitle(text)
 	}
 
+	a.pollReads()
+
+	a.pollSamplers()
+
 	if a.running && now.Sub(a.stepTime) >= a.stepInterval {
 		a.stepTime = now
 		a.clockCycles += uint64(a.stepMultiplier)
-		a.simulation.Step(a.stepMultiplier)
+		a.step(a.stepMultiplier)
+	}
+
+	// Read back the samples of this frame, so the waveform panel
+	// and breakpoints keep up with the simulation.
+	a.flushSamplers()
+}
+
+// step advances the simulation by n generations. If a recording is in
+// progress, the generations it needs are captured along the way. So are
+// the generations kept for stepping back.
+func (a *Application) step(n int) {
+	end := a.simulation.Generation() + uint64(n)
+
+	for n > 0 {
+		gen := a.simulation.Generation()
+		k := n
+		if a.recorder != nil {
+			until := a.recorder.Next() - gen
+			if until > 0 && until < uint64(k) {
+				k = int(until)
+			}
+		}
+
+		if until := a.rewind.Next(gen, end) - gen; until < uint64(k) {
+			k = int(until)
+		}
+
+		brk, ok := a.nextBreakGeneration(gen)
+		if ok && brk-gen < uint64(k) {
+			k = int(brk - gen)
+		}
+
+		a.simulation.Step(k)
+		a.rewind.Capture(a.simulation)
+		n -= k
+
+		if a.recorder != nil && a.recorder.Next() == a.simulation.Generation() {
+			a.recordFrame()
+		}
+
+		if ok && brk == a.simulation.Generation() {
+			log.Printf("breakpoint reached: generation %d", brk)
+			a.running = false
+			return
+		}
+	}
+}
+
+// stepBack sets the simulation back by n generations and stops it. If the
+// history does not reach that far, it goes back as far as it can.
+func (a *Application) stepBack(n int) {
+	gen := a.simulation.Generation()
+	oldest, ok := a.rewind.Oldest()
+	if !ok || oldest >= gen {
+		log.Println("no earlier generations available")
+		return
+	}
+
+	target := oldest
+	if uint64(n) < gen-oldest {
+		target = gen - uint64(n)
+	}
+
+	a.running = false
+	a.stopRecording()
+
+	if err := a.rewind.Restore(a.simulation, target); err != nil {
+		log.Println("step back failed:", err)
 	}
 }
 
@@ -141,8 +361,34 @@ func (a *Application) Update() {
 func (a *Application) Draw() {
 	w, h := a.window.GetFramebufferSize()
 	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+
+	// The simulation gets the part of the window above the waveform
+	// panel. Its projection keeps the origin in the top-left corner, so
+	// cells stay where they are when the panel is shown.
+	view := a.displaySize()
+	gl.Viewport(0, int32(h-view.Y), int32(view.X), int32(view.Y))
+	if view.Y != h {
+		a.setProjection(view)
+	}
+
+	textures := []Bindable{a.simulation, a.overlay, nil, nil}
+	if a.heatmap != nil {
+		a.display.SetHeatScale(a.heatmap.Scale())
+		textures[2] = a.heatmap
+	}
+	if a.netLabels != nil {
+		textures[3] = a.netLabels
+	}
+
+	a.display.Draw(textures...)
+
 	gl.Viewport(0, 0, int32(w), int32(h))
-	a.display.Draw(a.simulation)
+	if view.Y != h {
+		a.setProjection(image.Pt(w, h))
+	}
+
+	a.drawWaveform()
+	a.library.Draw()
 	a.window.SwapBuffers()
 }
 
@@ -160,48 +406,455 @@ func (a *Application) cursorPosCallback(window *glfw.Window, x, y float64) {
 	a.mouseDelta = a.mouse.Sub(pos)
 	a.mouse = pos
 
-	if a.display != nil && a.window.GetKey(glfw.KeySpace) != glfw.Release {
+	if a.display != nil && a.actions.Active("pan") {
 		a.display.Scroll(a.mouseDelta)
 	}
+
+	if a.display != nil && a.actions.Active("paint") {
+		a.paint()
+	}
+
+	if a.display != nil {
+		a.updatePreview()
+	}
 }
 
 func (a *Application) scrollCallback(window *glfw.Window, x, y float64) {
-	if a.display != nil {
-		a.display.Zoom(float32(y), a.mouse)
+	if a.display == nil {
+		return
+	}
+
+	mods := a.modifiers()
+
+	// The scroll amount is kept around for actions which care about it,
+	// like zooming. Most mouse wheels scroll in steps of 1.
+	if y != 0 {
+		a.scrollAmount = float32(gomath.Abs(y))
+		dir := ScrollUp
+		if y < 0 {
+			dir = ScrollDown
+		}
+		a.actions.Trigger(Binding{InputScroll, dir, mods})
+	}
+
+	if x != 0 {
+		a.scrollAmount = float32(gomath.Abs(x))
+		dir := ScrollRight
+		if x < 0 {
+			dir = ScrollLeft
+		}
+		a.actions.Trigger(Binding{InputScroll, dir, mods})
+	}
+}
+
+func (a *Application) mouseButtonCallback(window *glfw.Window, button glfw.MouseButton, action glfw.Action, mods glfw.ModifierKey) {
+	if a.display == nil {
+		return
+	}
+
+	b := Binding{InputMouse, int(button), mods}
+
+	switch action {
+	case glfw.Press:
+		a.actions.Press(b)
+	case glfw.Release:
+		a.actions.Release(b)
 	}
 }
 
 func (a *Application) keyCallback(window *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
-	if action != glfw.Press {
+	if a.display == nil {
 		return
 	}
 
-	switch key {
-	case glfw.KeyEscape:
-		window.SetShouldClose(true)
-	case glfw.KeyF1:
-		a.saveState()
-	case glfw.KeyF2:
-		a.loadState()
-	case glfw.KeyF5:
-		a.reload()
-	case glfw.KeyF11:
-		w, h := a.window.GetFramebufferSize()
-		a.setWindowMode(w, h, !a.config.Fullscreen)
-	case glfw.KeyC:
-		w, h := a.window.GetFramebufferSize()
-		a.display.Center(math.Vec2{float32(w), float32(h)})
-	case glfw.KeyQ:
-		a.running = !a.running
-	case glfw.KeyE:
-		a.simulation.Step(1)
-	case glfw.KeyW:
-		a.increaseClockspeed()
-	case glfw.KeyS:
-		a.decreaseClockspeed()
+	b := Binding{InputKey, int(key), mods}
+
+	switch action {
+	case glfw.Press:
+		a.actions.Press(b)
+	case glfw.Release:
+		a.actions.Release(b)
+	}
+}
+
+// modifiers returns the modifier keys which are currently held down.
+func (a *Application) modifiers() glfw.ModifierKey {
+	var mods glfw.ModifierKey
+
+	for _, m := range []struct {
+		mod  glfw.ModifierKey
+		keys [2]glfw.Key
+	}{
+		{glfw.ModShift, [2]glfw.Key{glfw.KeyLeftShift, glfw.KeyRightShift}},
+		{glfw.ModControl, [2]glfw.Key{glfw.KeyLeftControl, glfw.KeyRightControl}},
+		{glfw.ModAlt, [2]glfw.Key{glfw.KeyLeftAlt, glfw.KeyRightAlt}},
+		{glfw.ModSuper, [2]glfw.Key{glfw.KeyLeftSuper, glfw.KeyRightSuper}},
+	} {
+		for _, key := range m.keys {
+			if a.window.GetKey(key) != glfw.Release {
+				mods |= m.mod
+			}
+		}
+	}
+
+	return mods
+}
+
+// setClockspeed sets the clock to the given number of generations per second.
+func (a *Application) setClockspeed(hz int) {
+	// See decreaseClockspeed for details on the step multiplier.
+	if hz <= 1000 {
+		a.stepInterval = time.Second / time.Duration(hz)
+		a.stepMultiplier = 1
+	} else {
+		a.stepInterval = time.Millisecond
+		a.stepMultiplier = hz / 1000
+	}
+}
+
+// cellAt returns the simulation cell at the given screen position.
+func (a *Application) cellAt(pos math.Vec2) image.Point {
+	c := a.display.ScreenToCell(pos)
+	return image.Pt(int(math.Floor(c[0])), int(math.Floor(c[1])))
+}
+
+// beginPaint starts using the active tool at the mouse cursor, if edit
+// mode is enabled. Otherwise, it highlights the net under the cursor.
+func (a *Application) beginPaint() {
+	// Everything drawn while the paint action is held is undone at once.
+	a.history.Begin()
+
+	if c := a.library.ComponentAt(a.mouse); c != nil {
+		a.pasteComponent(c)
+		return
+	}
+
+	if a.waveformLane() >= 0 {
+		return
+	}
+
+	cell := a.cellAt(a.mouse)
+	if !a.editor.Enabled {
+		a.highlightNet(cell)
+		return
+	}
+
+	if a.editor.Paste != nil {
+		a.placePaste(cell)
+		return
+	}
+
+	if a.editor.Tool == ToolProbe {
+		a.toggleProbe(cell)
+		return
+	}
+
+	if a.editor.Tool == ToolDelay {
+		a.measureDelay(cell)
+		return
+	}
+	a.editor.Drawing = true
+	a.editor.Anchor = cell
+
+	switch a.editor.Tool {
+	case ToolBrush:
+		a.paintStroke(cell, cell)
+	case ToolFill:
+		// The region is found in the state as it was when the mouse was
+		// pressed. It is committed on release, so it can be previewed.
+		a.editor.Region = a.simulation.Cells(a.simulation.Bounds()).FloodRegion(cell)
+	}
+
+	a.updatePreview()
+}
+
+// paint continues using the active tool as the mouse cursor moves.
+func (a *Application) paint() {
+	if !a.editor.Drawing {
+		return
+	}
+
+	if a.editor.Tool == ToolBrush {
+		cell := a.cellAt(a.mouse)
+		a.paintStroke(a.editor.Anchor, cell)
+		a.editor.Anchor = cell
 	}
 }
 
+// endPaint commits the shape drawn by the active tool, if any.
+func (a *Application) endPaint() {
+	defer a.history.End()
+
+	if !a.editor.Drawing {
+		return
+	}
+
+	switch a.editor.Tool {
+	case ToolFill:
+		a.drawPoints(a.editor.Region, a.editor.Cell)
+	case ToolSelect:
+		r := spanRect(a.editor.Anchor, a.cellAt(a.mouse))
+		a.editor.Selection = r.Intersect(a.simulation.Bounds())
+	case ToolBreakpoint:
+		a.placeBreakpoint(spanRect(a.editor.Anchor, a.cellAt(a.mouse)))
+	default:
+		a.drawPoints(a.editor.Shape(a.cellAt(a.mouse)), a.editor.Cell)
+	}
+
+	a.editor.Drawing = false
+	a.editor.Region = nil
+	a.updatePreview()
+}
+
+// paintStroke stamps the brush along the line between the given cells.
+// Moving the mouse quickly skips cells, so the stroke fills the gap.
+func (a *Application) paintStroke(from, to image.Point) {
+	r := a.editor.BrushRect(from).Union(a.editor.BrushRect(to))
+	r = r.Intersect(a.simulation.Bounds())
+	if r.Empty() {
+		return
+	}
+
+	g := NewGrid(r.Dx(), r.Dy())
+	mask := make([]bool, len(g.Pix))
+	for _, p := range linePoints(from, to) {
+		br := a.editor.BrushRect(p).Intersect(r).Sub(r.Min)
+		for y := br.Min.Y; y < br.Max.Y; y++ {
+			for x := br.Min.X; x < br.Max.X; x++ {
+				g.Pix[y*g.Width+x] = a.editor.Cell
+				mask[y*g.Width+x] = true
+			}
+		}
+	}
+
+	a.applyEdit(r.Min, g, mask)
+}
+
+// drawPoints sets the given cells to the given state.
+func (a *Application) drawPoints(points []image.Point, cell byte) {
+	r := pointsBounds(points).Intersect(a.simulation.Bounds())
+	if r.Empty() {
+		return
+	}
+
+	g := NewGrid(r.Dx(), r.Dy())
+	mask := make([]bool, len(g.Pix))
+	for _, p := range points {
+		if p.In(r) {
+			i := (p.Y-r.Min.Y)*g.Width + p.X - r.Min.X
+			g.Pix[i] = cell
+			mask[i] = true
+		}
+	}
+
+	a.applyEdit(r.Min, g, mask)
+}
+
+// toggleLibrary shows or hides the component library. Showing it starts
+// placing the selected component.
+func (a *Application) toggleLibrary() {
+	if a.library.Len() == 0 {
+		log.Println("the component library is empty")
+		return
+	}
+
+	a.library.SetVisible(!a.library.Visible())
+	if a.library.Visible() {
+		a.pasteComponent(a.library.Selected())
+	}
+}
+
+// selectComponent moves the library selection by n places and starts
+// placing the newly selected component.
+func (a *Application) selectComponent(n int) {
+	if !a.library.Visible() {
+		return
+	}
+
+	a.library.SelectNext(n)
+	a.pasteComponent(a.library.Selected())
+}
+
+// setTool selects the active editor tool. A shape which is being drawn
+// is abandoned.
+func (a *Application) setTool(t Tool) {
+	a.editor.Tool = t
+	a.editor.Drawing = false
+	a.editor.Region = nil
+	a.updatePreview()
+}
+
+// setCell selects the cell state to draw with.
+func (a *Application) setCell(cell byte) {
+	a.editor.Cell = cell
+	a.updatePreview()
+}
+
+// setBrush sets the brush size.
+func (a *Application) setBrush(size int) {
+	a.editor.SetBrush(size)
+	a.updatePreview()
+}
+
+// updatePreview redraws the overlay to show what the active tool is
+// about to change. Outside edit mode, the overlay only marks the probes.
+func (a *Application) updatePreview() {
+	a.overlay.Clear()
+
+	if a.probes != nil {
+		for _, p := range a.probes.Probes() {
+			a.overlay.Set(p.X, p.Y, ProbeColor)
+		}
+
+		// Point out the probe of the waveform lane under the cursor.
+		if i := a.waveformLane(); i >= 0 {
+			p := a.probes.Probes()[i]
+			a.overlay.Set(p.X, p.Y, waveformCursor)
+		}
+	}
+
+	a.drawNet()
+	a.drawDeadWires()
+	a.drawLint()
+	a.drawDelay()
+	a.drawBreakpoints()
+	a.drawRegions()
+
+	if !a.editor.Enabled {
+		return
+	}
+
+	c := a.previewColor(a.editor.Cell)
+	cell := a.cellAt(a.mouse)
+
+	if !a.editor.Selection.Empty() {
+		r := a.editor.Selection
+		a.overlay.SetPoints(rectPoints(r.Min, r.Max.Sub(image.Pt(1, 1)), false), SelectionColor)
+	}
+
+	switch {
+	case a.editor.Paste != nil:
+		r := a.editor.PasteRect(cell)
+		g := a.editor.Paste
+		for y := 0; y < g.Height; y++ {
+			for x := 0; x < g.Width; x++ {
+				a.overlay.Set(r.Min.X+x, r.Min.Y+y, a.previewColor(g.Pix[y*g.Width+x]))
+			}
+		}
+
+		if c := a.editor.Component; c != nil {
+			for _, p := range c.Inputs {
+				a.overlay.Set(r.Min.X+p.X, r.Min.Y+p.Y, InputPinColor)
+			}
+			for _, p := range c.Outputs {
+				a.overlay.Set(r.Min.X+p.X, r.Min.Y+p.Y, OutputPinColor)
+			}
+		}
+	case a.editor.Tool == ToolSelect && a.editor.Drawing:
+		a.overlay.SetPoints(rectPoints(a.editor.Anchor, cell, false), SelectionColor)
+	case a.editor.Tool == ToolBreakpoint && a.editor.Drawing:
+		a.overlay.SetPoints(rectPoints(a.editor.Anchor, cell, false), BreakpointColor)
+	case a.editor.Tool == ToolBrush:
+		a.overlay.SetRect(a.editor.BrushRect(cell), c)
+	case a.editor.Tool == ToolFill && a.editor.Drawing:
+		a.overlay.SetPoints(a.editor.Region, c)
+	case a.editor.Drawing:
+		a.overlay.SetPoints(a.editor.Shape(cell), c)
+	default:
+		a.overlay.Set(cell.X, cell.Y, c)
+	}
+}
+
+// previewColor returns the overlay color for previews of the given cell
+// state. Empty cells are shown in the inverse of the empty color, or they
+// would not be visible at all.
+func (a *Application) previewColor(cell byte) color.RGBA {
+	if cell == CellEmpty {
+		e := a.config.Palette.Empty
+		return color.RGBA{0xff - e.R, 0xff - e.G, 0xff - e.B, 0x60}
+	}
+	return withAlpha(a.config.Palette.CellColor(cell), 0xa0)
+}
+
+// withAlpha returns c with the given alpha value.
+func withAlpha(c color.RGBA, alpha uint8) color.RGBA {
+	c.A = alpha
+	return c
+}
+
+// applyEdit overwrites the cells in the current simulation state with the
+// contents of g, placed at the given position. If mask is not nil, only the
+// cells whose entry in it is set are written. The change is recorded in the
+// undo history. Neither needs to wait for the GPU, so this is cheap enough
+// to call for every step of a brush stroke.
+func (a *Application) applyEdit(pos image.Point, g *Grid, mask []bool) {
+	r := g.Bounds().Add(pos).Intersect(a.simulation.Bounds())
+	if r.Empty() {
+		return
+	}
+
+	if a.history.Enabled() {
+		// The previous states are read before the edit is queued, so
+		// the GPU delivers them unchanged.
+		src := r.Sub(pos)
+		before := a.simulation.CellsAsync(r)
+		a.history.Push(newEditChange(r, before, g.Crop(src), cropMask(mask, g.Width, src)))
+	}
+
+	a.simulation.SetCellsMasked(pos, g, mask)
+	a.cellsChanged()
+}
+
+// cropMask returns the entries of a cell mask with the given row length
+// which lie in region r. Returns nil for a nil mask.
+func cropMask(mask []bool, width int, r image.Rectangle) []bool {
+	if mask == nil {
+		return nil
+	}
+
+	out := make([]bool, 0, r.Dx()*r.Dy())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		out = append(out, mask[y*width+r.Min.X:y*width+r.Max.X]...)
+	}
+	return out
+}
+
+// cellsChanged must be called after cells in the simulation have been
+// changed by anything other than the simulation rules.
+func (a *Application) cellsChanged() {
+	// Earlier generations no longer lead to the current state.
+	a.rewind.Reset(a.simulation)
+	a.resetCycles()
+	a.deadWires = nil
+	a.lint = nil
+	a.netsChanged()
+	a.delayChanged()
+}
+
+// undo reverts the most recent edit or simulation replacement.
+func (a *Application) undo() {
+	ok, err := a.history.Undo(a)
+	switch {
+	case err != nil:
+		log.Println("undo failed:", err)
+	case !ok:
+		log.Println("nothing to undo")
+	}
+	a.updatePreview()
+}
+
+// redo applies the most recently undone change again.
+func (a *Application) redo() {
+	ok, err := a.history.Redo(a)
+	switch {
+	case err != nil:
+		log.Println("redo failed:", err)
+	case !ok:
+		log.Println("nothing to redo")
+	}
+	a.updatePreview()
+}
+
 // decreaseClockspeed slows the clock down.
 func (a *Application) decreaseClockspeed() {
 	// Using just a time interval, we can't go above 1kHz.
@@ -318,6 +971,7 @@ func (a *Application) setWindowMode(width, height int, fullscreen bool) error {
 	a.window.SetFramebufferSizeCallback(a.framebufferSizeCallback)
 	a.window.SetScrollCallback(a.scrollCallback)
 	a.window.SetCursorPosCallback(a.cursorPosCallback)
+	a.window.SetMouseButtonCallback(a.mouseButtonCallback)
 	a.window.MakeContextCurrent()
 	a.window.SetSize(width, height)
 	a.window.SetPos(x, y)
@@ -331,7 +985,14 @@ func (a *Application) setWindowMode(width, height int, fullscreen bool) error {
 // updateUniformBlock updates matrix information in the shared uniform block.
 func (a *Application) updateUniformBlock() {
 	w, h := a.window.GetFramebufferSize()
-	p := math.Ortho2D(0, float32(w), float32(h), 0)
+	a.setProjection(image.Pt(w, h))
+}
+
+// setProjection sets the matrices in the shared uniform block for a
+// viewport of the given size in pixels, with the origin in its top-left
+// corner.
+func (a *Application) setProjection(size image.Point) {
+	p := math.Ortho2D(0, float32(size.X), float32(size.Y), 0)
 	v := math.Ident4()
 
 	const sizeofMat4 = len(math.Mat4{}) * 4
@@ -343,23 +1004,314 @@ func (a *Application) updateUniformBlock() {
 
 // reload reloads the original input image from disk.
 func (a *Application) reload() {
-	var err error
-
 	log.Println("reloading", a.config.Input)
+	a.stopRecording()
 
-	a.simulation, err = LoadSimulation(a.config.Input, &a.config.Palette)
+	sim, err := LoadSimulation(a.config.Input, &a.config.Palette)
 	if err != nil {
 		log.Println("load failed:", err)
+		return
+	}
+
+	a.replaceSimulation(sim)
+}
+
+// replaceSimulation replaces the current simulation with sim. Unlike
+// setSimulation, this records the old state in the undo history.
+func (a *Application) replaceSimulation(sim *Simulation) {
+	a.recordReplacement(nil)
+	a.setSimulation(sim)
+}
+
+// recordReplacement records the current simulation state in the undo
+// history, before it is replaced or resized. If the replacement moves the
+// markers, m holds them as they were before, so undo can put them back.
+func (a *Application) recordReplacement(m *markers) {
+	if !a.history.Enabled() {
+		return
+	}
+
+	snap, err := a.simulation.Snapshot()
+	if err != nil {
+		log.Println("failed to record undo history:", err)
+		return
+	}
+
+	a.history.Push(&replaceChange{snap, m})
+}
+
+// resizeCanvas changes the simulation bounds to r, given in the current
+// cell coordinates. The view is moved along, so the cells stay in place on
+// the screen.
+func (a *Application) resizeCanvas(r image.Rectangle) {
+	if r == a.simulation.Bounds() {
+		return
+	}
+
+	old := a.simulation.Size()
+	bounds := a.simulation.Bounds()
+	before := a.currentMarkers()
+	a.recordReplacement(before)
+
+	if err := a.simulation.Resize(r); err != nil {
+		log.Println("resize failed:", err)
+		return
+	}
+
+	log.Printf("canvas resized to %dx%d", r.Dx(), r.Dy())
+
+	// Markers stay on the same cells. Those which are cut off go.
+	if r.Min != (image.Point{}) || !bounds.In(r) {
+		a.setMarkers(before.crop(r))
+	}
+
+	// The display quad is centered on the scroll origin. Move the origin
+	// by the change in the position of the quad's center, in cells.
+	size := a.simulation.Size()
+	shift := math.Vec2{float32(r.Min.X), float32(r.Min.Y)}.Add(size.Sub(old).MulScalar(0.5))
+	a.display.Scroll(shift.MulScalar(-a.display.ZoomFactor()))
+
+	a.simulationChanged()
+}
+
+// markers holds the probes, breakpoints and population regions. They are
+// given in cell coordinates, so they must follow the cells when the canvas
+// is resized.
+type markers struct {
+	probes      []Probe
+	breakpoints []Breakpoint
+	regions     []Region
+}
+
+// currentMarkers returns a copy of the current markers.
+func (a *Application) currentMarkers() *markers {
+	return &markers{
+		probes:      a.currentProbes(),
+		breakpoints: append([]Breakpoint(nil), a.breakpoints...),
+		regions:     append([]Region(nil), a.regions...),
+	}
+}
+
+// setMarkers replaces the current markers with m.
+func (a *Application) setMarkers(m *markers) {
+	a.setProbes(m.probes)
+	a.setBreakpoints(m.breakpoints)
+	a.setRegions(m.regions)
+}
+
+// crop returns the markers in the coordinates of a canvas resized to r,
+// which is given in the current coordinates. Markers which lie entirely
+// outside r are dropped, and reported in the log.
+func (m *markers) crop(r image.Rectangle) *markers {
+	var out markers
+	bounds := image.Rect(0, 0, r.Dx(), r.Dy())
+
+	for _, p := range m.probes {
+		p.X -= r.Min.X
+		p.Y -= r.Min.Y
+		if !image.Pt(p.X, p.Y).In(bounds) {
+			log.Printf("removed probe %s, which is outside the canvas", p.Name)
+			continue
+		}
+		out.probes = append(out.probes, p)
+	}
+
+	for _, b := range m.breakpoints {
+		if _, ok := b.Counted(); ok {
+			b.Rect = b.Rect.Sub(r.Min)
+			if !b.Rect.Overlaps(bounds) {
+				log.Printf("removed breakpoint %q, which is outside the canvas", b.String())
+				continue
+			}
+		}
+		out.breakpoints = append(out.breakpoints, b)
+	}
+
+	for _, rg := range m.regions {
+		rg.Rect = rg.Rect.Sub(r.Min)
+		if !rg.Rect.Overlaps(bounds) {
+			log.Printf("removed region %s, which is outside the canvas", rg.Name)
+			continue
+		}
+		out.regions = append(out.regions, rg)
+	}
+
+	return &out
+}
+
+// growCanvas adds the configured number of empty cells to the given sides
+// of the canvas.
+func (a *Application) growCanvas(left, top, right, bottom bool) {
+	n := a.config.Grow
+	r := a.simulation.Bounds()
+
+	if left {
+		r.Min.X -= n
+	}
+	if top {
+		r.Min.Y -= n
 	}
+	if right {
+		r.Max.X += n
+	}
+	if bottom {
+		r.Max.Y += n
+	}
+
+	a.resizeCanvas(r)
+}
+
+// cropCanvas crops the canvas to the selection.
+func (a *Application) cropCanvas() {
+	r := a.editor.Selection.Intersect(a.simulation.Bounds())
+	if r.Empty() {
+		log.Println("nothing selected")
+		return
+	}
+
+	a.resizeCanvas(r)
+}
+
+// trimCanvas shrinks the canvas to the non-empty cells, plus the
+// configured margin.
+func (a *Application) trimCanvas() {
+	r, ok := a.simulation.Cells(a.simulation.Bounds()).TrimBounds(a.config.TrimMargin)
+	if !ok {
+		log.Println("nothing to trim to; all cells are empty")
+		return
+	}
+
+	a.resizeCanvas(r)
+}
+
+// setSimulation replaces the current simulation with sim.
+// The old simulation is released.
+func (a *Application) setSimulation(sim *Simulation) {
+	if a.simulation != nil {
+		a.simulation.Release()
+	}
+
+	// Pending reads of the old simulation still complete normally,
+	// because their pixel buffers are separate from its framebuffers.
+	a.simulation = sim
+	a.simulationChanged()
+}
+
+// simulationChanged updates everything which depends on the simulation,
+// after it has been replaced or resized.
+func (a *Application) simulationChanged() {
+	sim := a.simulation
+	a.display.SetSize(sim.Size())
+
+	if sim.Bounds() != a.overlay.Bounds() {
+		a.overlay.SetSize(sim.Size())
+	}
+
+	if a.rewind == nil || a.rewind.Size() != sim.Size() {
+		if a.rewind != nil {
+			a.rewind.Release()
+		}
+		c := a.config
+		a.rewind = NewRewind(sim.Size(), c.Rewind, c.RewindCheckpoints, uint64(c.RewindInterval))
+	}
+	a.rewind.Reset(sim)
+	a.resetCycles()
+	a.heatmapChanged()
+	a.deadWires = nil
+	a.lint = nil
+	a.netsChanged()
+	a.delayChanged()
+	a.updateSamplers()
+	a.editor.Drawing = false
+	a.editor.Region = nil
+	a.editor.Selection = image.Rectangle{}
+	a.updatePreview()
+}
+
+// readAsync starts an asynchronous read of the current simulation state.
+// The given function is called from Update, once the data is available.
+func (a *Application) readAsync(done func(pix []byte, size math.Vec2)) {
+	rb := a.simulation.ReadAsync()
+	a.pendingReads = append(a.pendingReads, pendingRead{rb, done})
+}
+
+// pollReads hands the data of all completed readbacks to their handlers.
+func (a *Application) pollReads() {
+	pending := a.pendingReads[:0]
+
+	for _, pr := range a.pendingReads {
+		if !pr.readback.Ready() {
+			pending = append(pending, pr)
+			continue
+		}
+
+		pr.done(pr.readback.Data(), pr.readback.Size())
+		pr.readback.Release()
+	}
+
+	a.pendingReads = pending
+}
+
+// outputFile returns the name of a new, timestamped file next to the
+// input file, with the given extension.
+func (a *Application) outputFile(ext string) string {
+	stamp := time.Now().UnixNano()
+	dir, name := a.inputName()
+	return filepath.Join(dir, fmt.Sprintf("%d.%s.%s", stamp, name, ext))
+}
+
+// inputName returns the directory where state files are stored, along
+// with the name of the input file, without extension. Input read from
+// stdin is given the name "stdin". State files are stored next to the
+// input file, unless a save directory is configured.
+func (a *Application) inputName() (string, string) {
+	var dir, name string
+
+	if a.config.Input == StdStream {
+		name = "stdin"
+	} else {
+		dir, name = filepath.Split(a.config.Input)
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+
+	if len(a.config.SaveDir) > 0 {
+		dir = a.config.SaveDir
+	}
+
+	return dir, name
 }
 
 // saveState writes the current simulation state as a PNG file.
+// The state is read asynchronously and the file is written in the
+// background once the data is available.
 func (a *Application) saveState() {
-	stamp := time.Now().UnixNano()
-	dir, name := filepath.Split(a.config.Input)
-	name = strings.Replace(name, filepath.Ext(name), "", -1)
-	file := filepath.Join(dir, fmt.Sprintf("%d.%s.png", stamp, name))
+	file := a.outputFile("png")
 
+	pal := a.config.Palette
+	ann := a.simulation.Annotations()
+	if a.config.Compact {
+		ann = nil
+	}
+
+	trim := a.config.Trim
+	margin := a.config.TrimMargin
+
+	a.readAsync(func(pix []byte, size math.Vec2) {
+		g := &Grid{pix, int(size[0]), int(size[1])}
+		if r, ok := g.TrimBounds(margin); trim && ok {
+			g = g.Crop(r)
+			if ann != nil {
+				ann = ann.Crop(r)
+			}
+		}
+
+		img := pal.fromInternalFormat(g.Pix, g.Size(), ann)
+		go writeStateFile(file, img)
+	})
+}
+
+// writeStateFile writes the given image to a PNG file.
+func writeStateFile(file string, img *image.Paletted) {
 	log.Println("saving state file", file)
 
 	fd, err := os.Create(file)
@@ -368,8 +1320,7 @@ func (a *Application) saveState() {
 		return
 	}
 
-	img := a.simulation.Image(&a.config.Palette)
-	if err = png.Encode(fd, img); err != nil {
+	if err = writeSnapshot(fd, img); err != nil {
 		log.Println("failed to encode image:", err)
 		_ = fd.Close()
 		return
@@ -380,9 +1331,91 @@ func (a *Application) saveState() {
 	}
 }
 
+// toggleRecording starts or stops recording an animation.
+func (a *Application) toggleRecording() {
+	if a.recorder != nil {
+		a.stopRecording()
+		return
+	}
+
+	// Whole state recordings are taken at their natural size. Viewport
+	// recordings capture the cells visible right now, at the current zoom.
+	var region image.Rectangle
+	scale := 1
+
+	if a.config.RecordViewport {
+		view := a.displaySize()
+		region = a.display.VisibleCells(math.Vec2{float32(view.X), float32(view.Y)})
+		scale = int(a.display.ZoomFactor() + 0.5)
+		if scale > a.config.RecordScale {
+			scale = a.config.RecordScale
+		}
+
+		if region.Empty() {
+			log.Println("nothing to record; the simulation is not visible")
+			return
+		}
+	}
+
+	file := a.outputFile(a.config.RecordFormat)
+	format := a.config.RecordFormat
+	delay := time.Duration(a.config.RecordDelay) * time.Millisecond
+
+	log.Println("recording", file)
+
+	a.recorder = NewRecorder(a.config, a.simulation.Generation(), region, scale, func(r *Recorder) {
+		go writeRecording(file, r, format, delay)
+	})
+	a.recordFrame()
+}
+
+// stopRecording ends the current recording, if any. The animation is written
+// to disk once all its frames have arrived.
+func (a *Application) stopRecording() {
+	if a.recorder == nil {
+		return
+	}
+
+	log.Println("recording stopped after", a.recorder.Len(), "frames")
+	a.recorder.Stop()
+	a.recorder = nil
+}
+
+// recordFrame captures the current simulation state for the recording.
+func (a *Application) recordFrame() {
+	a.readAsync(a.recorder.Capture())
+
+	if a.recorder.Full() {
+		a.stopRecording()
+	}
+}
+
+// writeRecording encodes the frames in r as an animation file.
+func writeRecording(file string, r *Recorder, format string, delay time.Duration) {
+	log.Println("saving recording", file)
+
+	fd, err := os.Create(file)
+	if err != nil {
+		log.Println("failed to create recording:", err)
+		return
+	}
+
+	if err = r.Encode(fd, format, delay); err != nil {
+		log.Println("failed to encode recording:", err)
+		_ = fd.Close()
+		return
+	}
+
+	if err = fd.Close(); err != nil {
+		log.Println("failed to save recording:", err)
+	}
+}
+
 // loadState loads the latest state of the input image from disk.
 func (a *Application) loadState() {
-	dir, name := filepath.Split(a.config.Input)
+	// State files are always PNG images, regardless of the input format.
+	dir, name := a.inputName()
+	name += ".png"
 
 	// Find all files matching the input.
 	stamps := findStateFiles(dir, name)
@@ -400,12 +1433,15 @@ func (a *Application) loadState() {
 	file = filepath.Join(dir, file)
 
 	log.Println("loading state", file)
+	a.stopRecording()
 
-	var err error
-	a.simulation, err = LoadSimulation(file, &a.config.Palette)
+	sim, err := LoadSimulation(file, &a.config.Palette)
 	if err != nil {
 		log.Println("failed to load state:", err)
+		return
 	}
+
+	a.replaceSimulation(sim)
 }
 
 // findStateFiles returns all files from the give directory which
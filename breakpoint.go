@@ -0,0 +1,229 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"strconv"
+)
+
+// BreakpointExt is appended to the name of a circuit file to find its
+// breakpoints.
+const BreakpointExt = ".breakpoints"
+
+// BreakKind defines the condition checked by a breakpoint.
+type BreakKind int
+
+// Known breakpoint kinds.
+const (
+	BreakState      BreakKind = iota // A cell in a region enters a state.
+	BreakHeads                       // The head count in a region crosses a threshold.
+	BreakGeneration                  // A generation is reached.
+)
+
+// Breakpoint pauses the simulation when its condition is met.
+type Breakpoint struct {
+	Kind       BreakKind
+	Rect       image.Rectangle // Region to check, for BreakState and BreakHeads.
+	State      byte            // Cell state to look for, for BreakState.
+	Above      bool            // Break when the head count rises above the threshold, rather than falls below it?
+	Threshold  int             // Head count threshold, for BreakHeads.
+	Generation uint64          // Generation to break at, for BreakGeneration.
+	Enabled    bool
+}
+
+// String returns the breakpoint in the format of a breakpoint file.
+func (b *Breakpoint) String() string {
+	var s string
+	r := b.Rect
+
+	switch b.Kind {
+	case BreakState:
+		if r.Dx() == 1 && r.Dy() == 1 {
+			s = fmt.Sprintf("cell %d %d %s", r.Min.X, r.Min.Y, cellName(b.State))
+		} else {
+			s = fmt.Sprintf("region %d %d %d %d %s", r.Min.X, r.Min.Y, r.Dx(), r.Dy(), cellName(b.State))
+		}
+	case BreakHeads:
+		dir := "below"
+		if b.Above {
+			dir = "above"
+		}
+		s = fmt.Sprintf("heads %d %d %d %d %s %d", r.Min.X, r.Min.Y, r.Dx(), r.Dy(), dir, b.Threshold)
+	case BreakGeneration:
+		s = fmt.Sprintf("generation %d", b.Generation)
+	}
+
+	if !b.Enabled {
+		s = "off " + s
+	}
+
+	return s
+}
+
+// Counted returns the cell state counted in the breakpoint's region.
+// Returns false if the breakpoint does not check a region.
+func (b *Breakpoint) Counted() (byte, bool) {
+	switch b.Kind {
+	case BreakState:
+		return b.State, true
+	case BreakHeads:
+		return CellHead, true
+	default:
+		return 0, false
+	}
+}
+
+// Triggered returns true if the breakpoint's condition is met when the
+// count of its cells changes from prev to cur between two generations.
+func (b *Breakpoint) Triggered(prev, cur int) bool {
+	switch b.Kind {
+	case BreakState:
+		return prev == 0 && cur > 0
+	case BreakHeads:
+		if b.Above {
+			return prev <= b.Threshold && cur > b.Threshold
+		}
+		return prev >= b.Threshold && cur < b.Threshold
+	default:
+		return false
+	}
+}
+
+// LoadBreakpoints reads breakpoints from the given file.
+func LoadBreakpoints(file string) ([]Breakpoint, error) {
+	var breakpoints breakpointList
+	if err := loadSidecar(file, "breakpoint", &breakpoints); err != nil {
+		return nil, err
+	}
+	return breakpoints, nil
+}
+
+// SaveBreakpoints writes the given breakpoints to a file.
+func SaveBreakpoints(file string, breakpoints []Breakpoint) error {
+	lines := make([]string, len(breakpoints))
+	for i := range breakpoints {
+		lines[i] = breakpoints[i].String()
+	}
+	return saveSidecar(file, lines)
+}
+
+// breakpointList collects the breakpoints of a breakpoint file.
+type breakpointList []Breakpoint
+
+// add parses a line of a breakpoint file, which holds one of:
+//
+//	cell <x> <y> <state>
+//	region <x> <y> <width> <height> <state>
+//	heads <x> <y> <width> <height> above|below <count>
+//	generation <n>
+//
+// Where state is empty, wire, head or tail. Lines starting with off define
+// disabled breakpoints.
+func (l *breakpointList) add(fields []string) error {
+	b, err := parseBreakpoint(fields)
+	if err != nil {
+		return err
+	}
+
+	*l = append(*l, b)
+	return nil
+}
+
+// parseBreakpoint parses the fields of a line in a breakpoint file.
+func parseBreakpoint(fields []string) (Breakpoint, error) {
+	b := Breakpoint{Enabled: true}
+
+	if len(fields) > 0 && fields[0] == "off" {
+		b.Enabled = false
+		fields = fields[1:]
+	}
+
+	if len(fields) == 0 {
+		return b, errors.New("missing breakpoint")
+	}
+
+	// ints parses the given fields as integers.
+	ints := func(fields []string) ([]int, error) {
+		out := make([]int, len(fields))
+		for i, f := range fields {
+			n, err := strconv.Atoi(f)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = n
+		}
+		return out, nil
+	}
+
+	var err error
+	var n []int
+	kind, args := fields[0], fields[1:]
+
+	switch kind {
+	case "cell":
+		if len(args) != 3 {
+			return b, errors.New("expected cell <x> <y> <state>")
+		}
+		if n, err = ints(args[:2]); err != nil {
+			return b, err
+		}
+		b.Kind = BreakState
+		b.Rect = image.Rect(n[0], n[1], n[0]+1, n[1]+1)
+		b.State, err = parseCellName(args[2])
+
+	case "region":
+		if len(args) != 5 {
+			return b, errors.New("expected region <x> <y> <width> <height> <state>")
+		}
+		if n, err = ints(args[:4]); err != nil {
+			return b, err
+		}
+		b.Kind = BreakState
+		b.Rect = image.Rect(n[0], n[1], n[0]+n[2], n[1]+n[3])
+		b.State, err = parseCellName(args[4])
+
+	case "heads":
+		if len(args) != 6 || (args[4] != "above" && args[4] != "below") {
+			return b, errors.New("expected heads <x> <y> <width> <height> above|below <count>")
+		}
+		if n, err = ints(append(args[:4:4], args[5])); err != nil {
+			return b, err
+		}
+		b.Kind = BreakHeads
+		b.Rect = image.Rect(n[0], n[1], n[0]+n[2], n[1]+n[3])
+		b.Above = args[4] == "above"
+		b.Threshold = n[4]
+
+	case "generation":
+		if len(args) != 1 {
+			return b, errors.New("expected generation <n>")
+		}
+		b.Kind = BreakGeneration
+		b.Generation, err = strconv.ParseUint(args[0], 10, 64)
+
+	default:
+		return b, fmt.Errorf("unknown breakpoint %q", kind)
+	}
+
+	if err != nil {
+		return b, err
+	}
+
+	if b.Kind != BreakGeneration && (b.Rect.Empty() || b.Rect.Min.X < 0 || b.Rect.Min.Y < 0) {
+		return b, errors.New("invalid region")
+	}
+
+	return b, nil
+}
+
+// parseCellName returns the cell state with the given name, as returned
+// by cellName.
+func parseCellName(name string) (byte, error) {
+	for _, cell := range []byte{CellEmpty, CellWire, CellHead, CellTail} {
+		if cellName(cell) == name {
+			return cell, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown cell state %q", name)
+}
@@ -0,0 +1,97 @@
+package main
+
+import "image"
+
+// BreakHit records a breakpoint whose condition was met.
+type BreakHit struct {
+	Index      int    // Index of the breakpoint.
+	Generation uint64 // Generation in which the condition was met.
+}
+
+// BreakpointEvaluator checks region breakpoints every generation, without
+// stalling the GPU.
+//
+// A RegionCounter counts the cells in each region after every simulation
+// step. The counts are read back in batches and compared with
+// those of the previous generation, to find the generations in which a
+// breakpoint triggered. Hits are therefore reported a frame or two after
+// they happened.
+type BreakpointEvaluator struct {
+	*RegionCounter
+	breakpoints []Breakpoint
+	index       []int   // Index of each evaluated breakpoint in the full list.
+	states      []uint8 // Palette index of the state each breakpoint counts.
+	prev        []int   // Counts of the last processed generation.
+	prevGen     uint64  // Last processed generation.
+	hits        []BreakHit
+}
+
+// NewBreakpointEvaluator creates an evaluator for the enabled region
+// breakpoints in the given list. Returns nil if there are none.
+func NewBreakpointEvaluator(breakpoints []Breakpoint) (*BreakpointEvaluator, error) {
+	var e BreakpointEvaluator
+	var rects []image.Rectangle
+
+	for i, b := range breakpoints {
+		state, ok := b.Counted()
+		if !ok || !b.Enabled {
+			continue
+		}
+
+		rects = append(rects, b.Rect)
+		e.states = append(e.states, CellIndex(state))
+		e.breakpoints = append(e.breakpoints, b)
+		e.index = append(e.index, i)
+	}
+
+	if len(e.breakpoints) == 0 {
+		return nil, nil
+	}
+
+	var err error
+	e.RegionCounter, err = NewRegionCounter(rects, e.process)
+	if err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+// Hits returns the breakpoints which triggered since the last call, in
+// the order in which they did.
+func (e *BreakpointEvaluator) Hits() []BreakHit {
+	hits := e.hits
+	e.hits = nil
+	return hits
+}
+
+// process checks the counts of consecutive generations, starting at first.
+// Only changes between directly consecutive generations count. So after the
+// simulation is reset, rewound or replaced, the first generation only sets
+// the counts to compare with.
+func (e *BreakpointEvaluator) process(first uint64, data []byte) {
+	n := len(e.breakpoints)
+	cur := make([]int, n)
+	counts := readCellCounts(data)
+
+	for row := 0; (row+1)*n <= len(counts); row++ {
+		gen := first + uint64(row)
+		for i := range cur {
+			cur[i] = counts[row*n+i][e.states[i]]
+		}
+
+		if e.prev != nil && gen == e.prevGen+1 {
+			for i, b := range e.breakpoints {
+				if b.Triggered(e.prev[i], cur[i]) {
+					e.hits = append(e.hits, BreakHit{e.index[i], gen})
+				}
+			}
+		}
+
+		if e.prev == nil {
+			e.prev = make([]int, n)
+		}
+		copy(e.prev, cur)
+		e.prevGen = gen
+	}
+}
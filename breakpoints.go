@@ -0,0 +1,225 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"log"
+)
+
+// Overlay colors for breakpoint regions.
+var (
+	BreakpointColor         = color.RGBA{0xff, 0x20, 0x20, 0xd0}
+	DisabledBreakpointColor = color.RGBA{0xff, 0x20, 0x20, 0x50}
+)
+
+// breakpointFile returns the name of the breakpoint file for the input.
+// Returns an empty string if there is none, as for input read from stdin.
+func (a *Application) breakpointFile() string {
+	return a.sidecarFile(a.config.Breakpoints, BreakpointExt)
+}
+
+// loadBreakpoints loads the breakpoints for the input, if it has any.
+func (a *Application) loadBreakpoints() {
+	a.loadSidecar("breakpoints", a.config.Breakpoints, BreakpointExt, func(file string) (int, error) {
+		breakpoints, err := LoadBreakpoints(file)
+		if err == nil {
+			a.setBreakpoints(breakpoints)
+		}
+		return len(breakpoints), err
+	})
+}
+
+// saveBreakpoints writes the breakpoints to the breakpoint file of the input.
+func (a *Application) saveBreakpoints() {
+	file := a.breakpointFile()
+	if len(file) == 0 {
+		log.Println("input from stdin has no breakpoint file; use -breakpoints to name one")
+		return
+	}
+
+	if err := SaveBreakpoints(file, a.breakpoints); err != nil {
+		log.Println("failed to save breakpoints:", err)
+		return
+	}
+
+	log.Println("saved breakpoints to", file)
+}
+
+// setBreakpoints replaces the breakpoints and starts evaluating them.
+func (a *Application) setBreakpoints(breakpoints []Breakpoint) {
+	a.breakpoints = breakpoints
+
+	if a.evaluator != nil {
+		a.evaluator.Release()
+		a.evaluator = nil
+	}
+
+	e, err := NewBreakpointEvaluator(breakpoints)
+	if err != nil {
+		log.Println("failed to create breakpoints:", err)
+	} else {
+		a.evaluator = e
+	}
+
+	a.updateSamplers()
+	a.updatePreview()
+}
+
+// listBreakpoints writes all breakpoints to the log, numbered from 1.
+func (a *Application) listBreakpoints() {
+	if len(a.breakpoints) == 0 {
+		log.Println("there are no breakpoints")
+		return
+	}
+
+	for i := range a.breakpoints {
+		log.Printf("breakpoint %d: %s", i+1, a.breakpoints[i].String())
+	}
+}
+
+// toggleBreakpoints disables all breakpoints if any of them is enabled.
+// Otherwise it enables all of them.
+func (a *Application) toggleBreakpoints() {
+	enable := true
+	for _, b := range a.breakpoints {
+		if b.Enabled {
+			enable = false
+		}
+	}
+
+	breakpoints := append([]Breakpoint(nil), a.breakpoints...)
+	for i := range breakpoints {
+		breakpoints[i].Enabled = enable
+	}
+
+	if enable {
+		log.Println("breakpoints enabled")
+	} else {
+		log.Println("breakpoints disabled")
+	}
+
+	a.setBreakpoints(breakpoints)
+}
+
+// breakpointAt returns the index of the last region breakpoint containing
+// the given cell. Returns -1 if there is none.
+func (a *Application) breakpointAt(cell image.Point) int {
+	for i := len(a.breakpoints) - 1; i >= 0; i-- {
+		b := &a.breakpoints[i]
+		if _, ok := b.Counted(); ok && cell.In(b.Rect) {
+			return i
+		}
+	}
+	return -1
+}
+
+// placeBreakpoint adds a breakpoint for the given region, which triggers
+// when a cell in it enters the editor's cell state. Clicking a single cell
+// of an existing breakpoint enables or disables it instead.
+func (a *Application) placeBreakpoint(r image.Rectangle) {
+	breakpoints := append([]Breakpoint(nil), a.breakpoints...)
+
+	if r.Dx() == 1 && r.Dy() == 1 {
+		if i := a.breakpointAt(r.Min); i >= 0 {
+			b := &breakpoints[i]
+			b.Enabled = !b.Enabled
+			log.Printf("breakpoint %d: %s", i+1, b.String())
+			a.setBreakpoints(breakpoints)
+			return
+		}
+	}
+
+	r = r.Intersect(a.simulation.Bounds())
+	if r.Empty() {
+		return
+	}
+
+	b := Breakpoint{Kind: BreakState, Rect: r, State: a.editor.Cell, Enabled: true}
+	breakpoints = append(breakpoints, b)
+	log.Printf("breakpoint %d: %s", len(breakpoints), b.String())
+	a.setBreakpoints(breakpoints)
+}
+
+// removeBreakpoint removes the region breakpoint under the mouse cursor.
+func (a *Application) removeBreakpoint() {
+	if !a.editor.Enabled || a.editor.Tool != ToolBreakpoint {
+		return
+	}
+
+	i := a.breakpointAt(a.cellAt(a.mouse))
+	if i < 0 {
+		return
+	}
+
+	log.Printf("removed breakpoint %d: %s", i+1, a.breakpoints[i].String())
+	breakpoints := append([]Breakpoint(nil), a.breakpoints[:i]...)
+	a.setBreakpoints(append(breakpoints, a.breakpoints[i+1:]...))
+}
+
+// nextBreakGeneration returns the first generation after gen with an
+// enabled generation breakpoint. Returns false if there is none.
+func (a *Application) nextBreakGeneration(gen uint64) (uint64, bool) {
+	var next uint64
+	found := false
+
+	for _, b := range a.breakpoints {
+		if b.Kind == BreakGeneration && b.Enabled && b.Generation > gen && (!found || b.Generation < next) {
+			next = b.Generation
+			found = true
+		}
+	}
+
+	return next, found
+}
+
+// checkBreakpoints handles the breakpoints which triggered since the last
+// call. The simulation is paused and set back to the generation of the
+// first hit, if the rewind buffer reaches back that far. Hits are ignored
+// while the simulation is not running. These come from generations which
+// are replayed when stepping back, or were simulated before it was paused.
+func (a *Application) checkBreakpoints() {
+	if a.evaluator == nil {
+		return
+	}
+
+	hits := a.evaluator.Hits()
+	if len(hits) == 0 || !a.running {
+		return
+	}
+
+	gen := hits[0].Generation
+	for _, h := range hits {
+		if h.Generation != gen {
+			break
+		}
+		log.Printf("breakpoint %d hit in generation %d: %s", h.Index+1, gen, a.breakpoints[h.Index].String())
+	}
+
+	a.running = false
+	a.stopRecording()
+
+	if gen >= a.simulation.Generation() {
+		return
+	}
+
+	if err := a.rewind.Restore(a.simulation, gen); err != nil {
+		log.Printf("can not go back to generation %d; stopped at %d", gen, a.simulation.Generation())
+	}
+}
+
+// drawBreakpoints marks the regions of the breakpoints in the overlay.
+func (a *Application) drawBreakpoints() {
+	for _, b := range a.breakpoints {
+		if _, ok := b.Counted(); !ok {
+			continue
+		}
+
+		c := BreakpointColor
+		if !b.Enabled {
+			c = DisabledBreakpointColor
+		}
+
+		r := b.Rect
+		a.overlay.SetPoints(rectPoints(r.Min, r.Max.Sub(image.Pt(1, 1)), false), c)
+	}
+}
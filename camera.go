@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hexaflex/wireworld-gpu/math"
+)
+
+// Keyframe defines the camera position and zoom at a given generation.
+type Keyframe struct {
+	Generation uint64    // Generation at which the keyframe applies.
+	Center     math.Vec2 // Cell coordinates in the center of the view.
+	Zoom       float32   // Size of a cell in pixels.
+}
+
+// CameraPath is a list of keyframes, sorted by generation. The camera moves
+// linearly between keyframes.
+type CameraPath []Keyframe
+
+// LoadCameraPath reads a camera path from the given file.
+//
+// Each line holds one keyframe in the form `<generation> <x> <y> <zoom>`,
+// where x and y are the cell coordinates in the center of the view.
+// Empty lines and lines starting with `#` are ignored.
+func LoadCameraPath(file string) (CameraPath, error) {
+	fd, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+
+	defer fd.Close()
+	return readCameraPath(fd)
+}
+
+// readCameraPath reads a camera path from r.
+func readCameraPath(r io.Reader) (CameraPath, error) {
+	var path CameraPath
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("camera path line %d: expected <generation> <x> <y> <zoom>", line)
+		}
+
+		var kf Keyframe
+		var values [3]float64
+		var err error
+
+		kf.Generation, err = strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("camera path line %d: invalid generation; %v", line, err)
+		}
+
+		for i := range values {
+			values[i], err = strconv.ParseFloat(fields[i+1], 32)
+			if err != nil {
+				return nil, fmt.Errorf("camera path line %d: invalid number; %v", line, err)
+			}
+		}
+
+		kf.Center = math.Vec2{float32(values[0]), float32(values[1])}
+		kf.Zoom = float32(values[2])
+
+		if kf.Zoom <= 0 {
+			return nil, fmt.Errorf("camera path line %d: zoom must be > 0", line)
+		}
+
+		path = append(path, kf)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(path, func(i, j int) bool {
+		return path[i].Generation < path[j].Generation
+	})
+
+	return path, nil
+}
+
+// At returns the camera state at the given generation. Generations before
+// the first and after the last keyframe use those keyframes as they are.
+func (p CameraPath) At(generation uint64) Keyframe {
+	if len(p) == 0 {
+		return Keyframe{Generation: generation, Zoom: 1}
+	}
+
+	i := sort.Search(len(p), func(i int) bool {
+		return p[i].Generation > generation
+	})
+
+	switch {
+	case i == 0:
+		return p[0]
+	case i == len(p):
+		return p[len(p)-1]
+	}
+
+	a, b := p[i-1], p[i]
+	t := float32(generation-a.Generation) / float32(b.Generation-a.Generation)
+
+	return Keyframe{
+		Generation: generation,
+		Center:     a.Center.Add(b.Center.Sub(a.Center).MulScalar(t)),
+		Zoom:       a.Zoom + (b.Zoom-a.Zoom)*t,
+	}
+}
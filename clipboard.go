@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"log"
+	"strings"
+)
+
+// copySelection copies the selected cells into the clipboard.
+// The system clipboard receives them in RLE format, so they can be
+// pasted into other instances of the program or other tools.
+func (a *Application) copySelection() {
+	r := a.editor.Selection.Intersect(a.simulation.Bounds())
+	if r.Empty() {
+		log.Println("nothing selected")
+		return
+	}
+
+	a.clipboard = a.simulation.Cells(r)
+
+	var buf bytes.Buffer
+	if err := writeRLE(&buf, a.clipboard); err != nil {
+		log.Println("failed to encode clipboard:", err)
+		return
+	}
+
+	a.window.SetClipboardString(buf.String())
+}
+
+// cutSelection copies the selected cells into the clipboard and clears them.
+func (a *Application) cutSelection() {
+	a.copySelection()
+
+	r := a.editor.Selection.Intersect(a.simulation.Bounds())
+	if r.Empty() {
+		return
+	}
+
+	a.applyEdit(r.Min, NewGrid(r.Dx(), r.Dy()), nil)
+}
+
+// paste starts pasting the clipboard contents. The cells float under the
+// mouse cursor until they are placed with the paint action.
+//
+// The system clipboard takes precedence, if it holds an RLE pattern.
+// Otherwise the last cells copied in this instance are used.
+func (a *Application) paste() {
+	g := a.clipboard
+	if text := a.window.GetClipboardString(); isRLE([]byte(text)) {
+		cg, err := readRLE(strings.NewReader(text))
+		if err != nil {
+			log.Println("failed to read clipboard:", err)
+		} else {
+			g = cg
+		}
+	}
+
+	if g == nil || len(g.Pix) == 0 {
+		log.Println("clipboard is empty")
+		return
+	}
+
+	a.editor.Enabled = true
+	a.editor.Drawing = false
+	a.editor.Paste = g.Clone()
+	a.editor.Component = nil
+	a.updatePreview()
+}
+
+// pasteComponent starts pasting the given library component.
+func (a *Application) pasteComponent(c *Component) {
+	a.editor.Enabled = true
+	a.editor.Drawing = false
+	a.editor.Component = c
+	a.editor.Paste = c.Cells.Clone()
+	a.updatePreview()
+}
+
+// rotatePaste rotates the cells being pasted 90 degrees clockwise.
+func (a *Application) rotatePaste() {
+	if c := a.editor.Component; c != nil {
+		a.pasteComponent(c.Rotate())
+	} else if a.editor.Paste != nil {
+		a.editor.Paste = a.editor.Paste.Rotate()
+		a.updatePreview()
+	}
+}
+
+// mirrorPaste mirrors the cells being pasted, either horizontally or vertically.
+func (a *Application) mirrorPaste(horizontal bool) {
+	if c := a.editor.Component; c != nil {
+		a.pasteComponent(c.Mirror(horizontal))
+		return
+	}
+
+	if a.editor.Paste == nil {
+		return
+	}
+
+	if horizontal {
+		a.editor.Paste.FlipH()
+	} else {
+		a.editor.Paste.FlipV()
+	}
+
+	a.updatePreview()
+}
+
+// placePaste writes the cells being pasted into the simulation, centered on
+// the given cell. Pasting continues afterwards, so the same cells can be
+// placed several times.
+func (a *Application) placePaste(cell image.Point) {
+	r := a.editor.PasteRect(cell)
+	a.applyEdit(r.Min, a.editor.Paste, nil)
+}
+
+// cancel stops pasting, or clears the selection if nothing is being pasted.
+func (a *Application) cancel() {
+	if a.editor.Paste != nil {
+		a.editor.Paste = nil
+		a.editor.Component = nil
+	} else {
+		a.editor.Selection = image.Rectangle{}
+	}
+
+	a.editor.Drawing = false
+	a.updatePreview()
+}
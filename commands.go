@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Command defines a command line tool which runs without opening a window.
+// It is selected by passing its name as the first program argument.
+type Command struct {
+	Name  string                                  // Name used to select the command.
+	Usage string                                  // Argument synopsis, excluding options.
+	Brief string                                  // Short description of what the command does.
+	Run   func(cmd *Command, args []string) error // Runs the command with the remaining arguments.
+}
+
+// commands lists all known commands.
+var commands = []*Command{
+	convertCommand,
+	renderCommand,
+	statsCommand,
+	composeCommand,
+	probeCommand,
+	periodCommand,
+	heatmapCommand,
+	deadWiresCommand,
+	netsCommand,
+	lintCommand,
+	delayCommand,
+}
+
+// findCommand returns the command with the given name.
+// Returns nil if there is no such command.
+func findCommand(name string) *Command {
+	for _, cmd := range commands {
+		if cmd.Name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// printCommands writes an overview of all commands to w.
+func printCommands(w io.Writer) {
+	fmt.Fprintln(w, "\ncommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.Name, cmd.Brief)
+	}
+	fmt.Fprintf(w, "\nUse '%s <command> -help' for the options of a command.\n", os.Args[0])
+}
+
+// newFlagSet returns a flag set for the given command, with a usage
+// function which documents the command arguments.
+func newFlagSet(cmd *Command) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.Name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s %s [options] %s\n", os.Args[0], cmd.Name, cmd.Usage)
+		fmt.Fprintf(fs.Output(), "%s\n\n", cmd.Brief)
+		fs.PrintDefaults()
+	}
+	return fs
+}
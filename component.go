@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ComponentDir is the default component library directory.
+const ComponentDir = "components"
+
+// circuitExts lists the file extensions of circuit files which can be
+// loaded as components.
+var circuitExts = []string{".rle", ".png", ".gif", ".jpg", ".jpeg", ".pbm", ".pgm", ".ppm", ".pnm"}
+
+// Pin defines a named input or output of a component. It is the cell at the
+// end of the wire which carries the signal.
+type Pin struct {
+	Name string `json:"name"`
+	X    int    `json:"x"`
+	Y    int    `json:"y"`
+}
+
+// Component is a reusable circuit from the component library.
+//
+// The cells are loaded from any supported circuit file. The pins and a
+// description are read from a JSON file with the same name and a .json
+// extension. That file is optional.
+type Component struct {
+	Name        string `json:"-"` // Name of the circuit file, without extension.
+	Description string `json:"description"`
+	Inputs      []Pin  `json:"inputs"`
+	Outputs     []Pin  `json:"outputs"`
+	Cells       *Grid  `json:"-"`
+}
+
+// LoadComponent loads a component from the given circuit file.
+// It uses the given color palette to recognize cell states.
+func LoadComponent(file string, pal *Palette) (*Component, error) {
+	cells, err := LoadGrid(file, pal)
+	if err != nil {
+		return nil, err
+	}
+
+	base := strings.TrimSuffix(file, filepath.Ext(file))
+	c := &Component{
+		Name:  filepath.Base(base),
+		Cells: cells,
+	}
+
+	data, err := ioutil.ReadFile(base + ".json")
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("%s.json: %v", base, err)
+	}
+
+	for _, p := range c.Pins() {
+		if p.X < 0 || p.Y < 0 || p.X >= cells.Width || p.Y >= cells.Height {
+			return nil, fmt.Errorf("%s.json: pin %q lies outside the circuit", base, p.Name)
+		}
+	}
+
+	return c, nil
+}
+
+// LoadLibrary loads all components from the given directory,
+// sorted by name.
+func LoadLibrary(dir string, pal *Palette) ([]*Component, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var lib []*Component
+	for _, fi := range files {
+		if fi.IsDir() || !isCircuitFile(fi.Name()) {
+			continue
+		}
+
+		c, err := LoadComponent(filepath.Join(dir, fi.Name()), pal)
+		if err != nil {
+			return nil, err
+		}
+
+		lib = append(lib, c)
+	}
+
+	sort.Slice(lib, func(i, j int) bool {
+		return lib[i].Name < lib[j].Name
+	})
+
+	return lib, nil
+}
+
+// isCircuitFile returns true if the given file name has the extension
+// of a supported circuit format.
+func isCircuitFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, e := range circuitExts {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// findComponent returns the component with the given name.
+// Returns nil if there is no such component.
+func findComponent(lib []*Component, name string) *Component {
+	for _, c := range lib {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// Pins returns all input and output pins.
+func (c *Component) Pins() []Pin {
+	return append(append([]Pin{}, c.Inputs...), c.Outputs...)
+}
+
+// Rotate returns a copy of the component, rotated 90 degrees clockwise.
+func (c *Component) Rotate() *Component {
+	h := c.Cells.Height
+	return c.transform(c.Cells.Rotate(), func(p Pin) Pin {
+		p.X, p.Y = h-1-p.Y, p.X
+		return p
+	})
+}
+
+// Mirror returns a copy of the component, mirrored horizontally or
+// vertically.
+func (c *Component) Mirror(horizontal bool) *Component {
+	cells := c.Cells.Clone()
+	w, h := cells.Width, cells.Height
+
+	if horizontal {
+		cells.FlipH()
+		return c.transform(cells, func(p Pin) Pin {
+			p.X = w - 1 - p.X
+			return p
+		})
+	}
+
+	cells.FlipV()
+	return c.transform(cells, func(p Pin) Pin {
+		p.Y = h - 1 - p.Y
+		return p
+	})
+}
+
+// Transform returns the component mirrored horizontally if mirror is true,
+// and then rotated clockwise by the given number of degrees. The rotation
+// must be a multiple of 90.
+func (c *Component) Transform(rotation int, mirror bool) (*Component, error) {
+	if rotation%90 != 0 {
+		return nil, fmt.Errorf("rotation %d is not a multiple of 90", rotation)
+	}
+
+	out := c
+	if mirror {
+		out = out.Mirror(true)
+	}
+
+	for n := ((rotation / 90 % 4) + 4) % 4; n > 0; n-- {
+		out = out.Rotate()
+	}
+
+	return out, nil
+}
+
+// transform returns a copy of the component with the given cells, and
+// pins moved by fn.
+func (c *Component) transform(cells *Grid, fn func(Pin) Pin) *Component {
+	out := *c
+	out.Cells = cells
+	out.Inputs = make([]Pin, len(c.Inputs))
+	out.Outputs = make([]Pin, len(c.Outputs))
+
+	for i, p := range c.Inputs {
+		out.Inputs[i] = fn(p)
+	}
+
+	for i, p := range c.Outputs {
+		out.Outputs[i] = fn(p)
+	}
+
+	return &out
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+)
+
+var composeCommand = &Command{
+	Name:  "compose",
+	Usage: "<manifest>",
+	Brief: "Assembles the circuit files and components listed in a manifest into a single circuit.",
+	Run:   runCompose,
+}
+
+func runCompose(cmd *Command, args []string) error {
+	var pal Palette
+	pal.LoadDefault()
+
+	fs := newFlagSet(cmd)
+	paletteFlags(fs, &pal)
+	output := fs.String("o", StdStream, "Output file, or - for stdout.")
+	format := fs.String("format", "", "Output format: png or rle. Defaults to the output file extension, or png.")
+	pins := fs.String("pins", "", "Write the positions of the pins of all placed components to this file, or - for stdout.")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("missing manifest file")
+	}
+
+	if err := checkOutputFormat(*output, format); err != nil {
+		return err
+	}
+
+	data, err := readInput(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if !isManifest(data) {
+		return fmt.Errorf("%s is not a manifest", fs.Arg(0))
+	}
+
+	a, err := loadManifest(fs.Arg(0), data, &pal, nil)
+	if err != nil {
+		return err
+	}
+
+	if len(*pins) > 0 {
+		if err := writePins(*pins, a.Components); err != nil {
+			return err
+		}
+	}
+
+	return writeGrid(*output, *format, a.Cells, &pal, a.Annotations)
+}
+
+// writePins writes the position of every pin of the placed components to
+// the given file. Each line holds the component index and name, the pin
+// direction and name, and its position in the composed circuit.
+func writePins(file string, parts []Placed) error {
+	w, err := createOutput(file)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	for i, c := range parts {
+		for _, set := range []struct {
+			dir  string
+			pins []Pin
+		}{{"in", c.Inputs}, {"out", c.Outputs}} {
+			for _, p := range set.pins {
+				fmt.Fprintf(bw, "%d %s %s %s %d %d\n", i, c.Name, set.dir, p.Name, c.Pos.X+p.X, c.Pos.Y+p.Y)
+			}
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		w.Close()
+		return err
+	}
+
+	return w.Close()
+}
@@ -1,90 +1,534 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"image/color"
+	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 )
 
+// ConfigFile is the name of the configuration file in the user's
+// configuration directory.
+const ConfigFile = "config.json"
+
 // Config defines application settings.
+//
+// Settings are layered. Defaults are overridden by the configuration file,
+// which is in turn overridden by command line flags.
 type Config struct {
-	Input      string  // Image file with simulation data to load.
-	Width      int     // Display width in pixels.
-	Height     int     // Display height in pixels.
-	Palette    Palette // Color palette to use.
-	Fullscreen bool    // Run in fullscreen mode?
+	Input       string  `json:"-"`           // File with simulation data to load, or - for stdin.
+	Probes      string  `json:"-"`           // Probe file. Defaults to the input file name with ProbeExt appended.
+	Breakpoints string  `json:"-"`           // Breakpoint file. Defaults to the input file name with BreakpointExt appended.
+	Regions     string  `json:"-"`           // Region file. Defaults to the input file name with RegionExt appended.
+	Width       int     `json:"width"`       // Display width in pixels.
+	Height      int     `json:"height"`      // Display height in pixels.
+	Fullscreen  bool    `json:"fullscreen"`  // Run in fullscreen mode?
+	Palette     Palette `json:"palette"`     // Color palette to use.
+	Zoom        float32 `json:"zoom"`        // Initial zoom level.
+	Speed       int     `json:"speed"`       // Initial simulation speed in generations per second.
+	SaveDir     string  `json:"save_dir"`    // Directory for state files and recordings. Defaults to the input directory.
+	Compact     bool    `json:"compact"`     // Write snapshots at 2 bits per pixel, without annotations?
+	History     int     `json:"history"`     // Memory budget for undo history in MiB. 0 disables undo.
+	Library     string  `json:"library"`     // Component library directory.
+	Grow        int     `json:"grow"`        // Empty cells added to a side of the canvas when growing it.
+	Trim        bool    `json:"trim"`        // Trim saved states to their non-empty cells?
+	TrimMargin  int     `json:"trim_margin"` // Empty cells kept around the non-empty cells when trimming.
+
+	ProbeHistory   int `json:"probe_history"`   // Maximum number of generations of probe samples to keep.
+	WaveformLength int `json:"waveform_length"` // Number of generations shown in the waveform panel.
+	WaveformLane   int `json:"waveform_lane"`   // Height of a waveform lane in pixels.
+
+	CycleDetect   bool `json:"cycle_detect"`   // Detect repeating states while the simulation runs?
+	CyclePause    bool `json:"cycle_pause"`    // Pause the simulation when a repeating state is found?
+	CycleInterval int  `json:"cycle_interval"` // Compare the states of every Nth generation.
+	CycleLimit    int  `json:"cycle_limit"`    // Maximum number of states remembered by cycle detection.
+
+	Population        bool `json:"population"`         // Count the cells in each state every generation?
+	PopulationHistory int  `json:"population_history"` // Maximum number of generations of population counts to keep.
+
+	Heatmap    bool `json:"heatmap"`     // Show the heatmap of electron activity?
+	HeatWindow int  `json:"heat_window"` // Generations counted in the heatmap, or 0 for all of them.
+
+	Rewind            int `json:"rewind"`             // Number of recent generations kept for stepping back.
+	RewindCheckpoints int `json:"rewind_checkpoints"` // Number of checkpoints kept for stepping back further.
+	RewindInterval    int `json:"rewind_interval"`    // Generations between two checkpoints.
+	StepBack          int `json:"step_back"`          // Generations to go back with the rewind action.
+
+	RecordFormat   string `json:"record_format"`   // Animation format for recordings: gif or apng.
+	RecordEvery    int    `json:"record_every"`    // Capture every Nth generation while recording.
+	RecordFrames   int    `json:"record_frames"`   // Maximum number of frames in a recording.
+	RecordScale    int    `json:"record_scale"`    // Maximum scale factor for recorded frames.
+	RecordDelay    int    `json:"record_delay"`    // Delay between animation frames in milliseconds.
+	RecordViewport bool   `json:"record_viewport"` // Record only the visible part of the simulation?
+
+	Keys map[string][]string `json:"keys"` // Inputs bound to each action.
 }
 
-// parseArgs parses commandline arguments and returns a config struct.
-// Exits the program with an error if invalid data was found.
-func parseArgs() *Config {
+// defaultConfig returns a config with default settings.
+func defaultConfig() *Config {
 	var c Config
 	c.Width = 1280
 	c.Height = 600
 	c.Fullscreen = false
 	c.Palette.LoadDefault()
+	c.Zoom = DefaultZoom
+	c.Speed = 100
+	c.History = 256
+	c.Library = ComponentDir
+	c.Grow = 64
+	c.TrimMargin = 4
+	c.ProbeHistory = 1000000
+	c.WaveformLength = 256
+	c.WaveformLane = 16
+	c.CycleInterval = 1
+	c.CycleLimit = 1000000
+	c.PopulationHistory = 1000000
+	c.Rewind = 16
+	c.RewindCheckpoints = 16
+	c.RewindInterval = 100
+	c.StepBack = 10
+	c.RecordFormat = FormatGIF
+	c.RecordEvery = 1
+	c.RecordFrames = 500
+	c.RecordScale = 8
+	c.RecordDelay = 100
+	c.Keys = defaultKeys()
+	return &c
+}
 
-	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "usage: %s [options] <image>\n", os.Args[0])
-		flag.PrintDefaults()
+// defaultKeys returns the default key bindings for all actions.
+func defaultKeys() map[string][]string {
+	return map[string][]string{
+		"quit":               {"Escape"},
+		"save-state":         {"F1"},
+		"load-state":         {"F2"},
+		"toggle-recording":   {"F3"},
+		"reload":             {"F5"},
+		"toggle-fullscreen":  {"F11"},
+		"center":             {"C"},
+		"toggle-run":         {"Q"},
+		"step":               {"E"},
+		"step-back":          {"Shift+E"},
+		"rewind":             {"Ctrl+E"},
+		"speed-up":           {"W"},
+		"speed-down":         {"S"},
+		"pan":                {"Space", "MouseMiddle"},
+		"zoom-in":            {"ScrollUp"},
+		"zoom-out":           {"ScrollDown"},
+		"toggle-edit":        {"Tab"},
+		"paint":              {"MouseLeft"},
+		"tool-brush":         {"B"},
+		"tool-line":          {"L"},
+		"tool-rect":          {"R"},
+		"tool-filled-rect":   {"Shift+R"},
+		"tool-fill":          {"F"},
+		"tool-select":        {"M"},
+		"tool-probe":         {"O"},
+		"export-vcd":         {"F4"},
+		"save-probes":        {"Shift+F4"},
+		"toggle-waveform":    {"V"},
+		"tool-breakpoint":    {"D"},
+		"remove-breakpoint":  {"Shift+MouseLeft"},
+		"tool-delay":         {"J"},
+		"list-breakpoints":   {"Shift+D"},
+		"toggle-breakpoints": {"Ctrl+D"},
+		"save-breakpoints":   {"Ctrl+Shift+D"},
+		"toggle-cycles":      {"Y"},
+		"toggle-population":  {"N"},
+		"export-population":  {"Shift+N"},
+		"toggle-heatmap":     {"A"},
+		"export-heatmap":     {"Shift+A"},
+		"clear-heatmap":      {"Ctrl+A"},
+		"find-dead-wires":    {"X"},
+		"lint":               {"I"},
+		"copy":               {"Ctrl+C"},
+		"cut":                {"Ctrl+X"},
+		"paste":              {"Ctrl+V"},
+		"rotate":             {"T"},
+		"mirror-horizontal":  {"H"},
+		"mirror-vertical":    {"Shift+H"},
+		"cancel":             {"Backspace", "MouseRight"},
+		"grow-canvas":        {"G"},
+		"grow-left":          {"Ctrl+Left"},
+		"grow-right":         {"Ctrl+Right"},
+		"grow-up":            {"Ctrl+Up"},
+		"grow-down":          {"Ctrl+Down"},
+		"crop":               {"K"},
+		"trim":               {"Shift+K"},
+		"toggle-library":     {"P"},
+		"next-component":     {"Down"},
+		"prev-component":     {"Up"},
+		"undo":               {"Ctrl+Z"},
+		"redo":               {"Ctrl+Y", "Ctrl+Shift+Z"},
+		"select-empty":       {"1"},
+		"select-wire":        {"2"},
+		"select-head":        {"3"},
+		"select-tail":        {"4"},
+		"brush-grow":         {"RightBracket"},
+		"brush-shrink":       {"LeftBracket"},
 	}
+}
 
-	palEmpty := flag.String("pal-empty", hexStr(c.Palette.Empty), "Color for empty cells.")
-	palWire := flag.String("pal-wire", hexStr(c.Palette.Wire), "Color for wire cells.")
-	palHead := flag.String("pal-head", hexStr(c.Palette.Head), "Color for electron head cells.")
-	palTail := flag.String("pal-tail", hexStr(c.Palette.Tail), "Color for electron tail cells.")
-
-	flag.IntVar(&c.Width, "width", c.Width, "Display width in pixels.")
-	flag.IntVar(&c.Height, "height", c.Height, "Display height in pixels.")
-	flag.BoolVar(&c.Fullscreen, "fullscreen", c.Fullscreen, "Use a fullscreen display.")
-	version := flag.Bool("version", false, "Displays version information.")
-	flag.Parse()
+// parseArgs parses commandline arguments and returns a config struct.
+// Returns an error if invalid data was found.
+func parseArgs(args []string) (*Config, error) {
+	// The flags are parsed twice. First to find the configuration file,
+	// then again to override the settings loaded from that file.
+	fs, opt := configFlags(defaultConfig())
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
 
-	if *version {
+	if opt.version {
 		fmt.Println(Version())
 		os.Exit(0)
 	}
 
-	if flag.NArg() == 0 {
-		fmt.Fprintln(os.Stderr, "missing input image")
-		flag.Usage()
-		os.Exit(1)
+	c := defaultConfig()
+
+	if len(opt.config) > 0 {
+		if err := c.Load(opt.config); err != nil {
+			return nil, err
+		}
+	} else if file, err := defaultConfigFile(); err == nil {
+		if err := c.Load(file); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	fs, opt = configFlags(c)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	if opt.printConfig {
+		if err := c.Write(os.Stdout); err != nil {
+			return nil, err
+		}
+		os.Exit(0)
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return nil, errors.New("missing input file")
 	}
 
-	c.Input = flag.Arg(0)
+	c.Input = fs.Arg(0)
+	return c, nil
+}
+
+// configOptions holds flags which are not stored in the config itself.
+type configOptions struct {
+	config      string
+	printConfig bool
+	version     bool
+}
+
+// configFlags returns a flag set which stores its values in c.
+func configFlags(c *Config) (*flag.FlagSet, *configOptions) {
+	var opt configOptions
 
-	if c.Width <= 0 {
-		fmt.Fprintf(os.Stderr, "width must be > 0")
-		flag.Usage()
-		os.Exit(1)
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [options] <file>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "   or: %s <command> [options] <args>\n", os.Args[0])
+		fs.PrintDefaults()
+		printCommands(os.Stderr)
 	}
 
-	if c.Height <= 0 {
-		fmt.Fprintf(os.Stderr, "height must be > 0")
-		flag.Usage()
-		os.Exit(1)
+	paletteFlags(fs, &c.Palette)
+
+	fs.IntVar(&c.Width, "width", c.Width, "Display width in pixels.")
+	fs.IntVar(&c.Height, "height", c.Height, "Display height in pixels.")
+	fs.BoolVar(&c.Fullscreen, "fullscreen", c.Fullscreen, "Use a fullscreen display.")
+	fs.Var(zoomValue{&c.Zoom}, "zoom", "Initial zoom level.")
+	fs.IntVar(&c.Speed, "speed", c.Speed, "Initial simulation speed in generations per second.")
+	fs.StringVar(&c.SaveDir, "save-dir", c.SaveDir, "Directory for state files and recordings. Defaults to the input directory.")
+	fs.BoolVar(&c.Compact, "compact", c.Compact, "Write snapshots at 2 bits per pixel, dropping annotation colors.")
+	fs.IntVar(&c.History, "history", c.History, "Memory budget for undo history in MiB. 0 disables undo.")
+	fs.StringVar(&c.Library, "library", c.Library, "Component library directory.")
+	fs.IntVar(&c.Grow, "grow", c.Grow, "Empty cells added to a side of the canvas when growing it.")
+	fs.BoolVar(&c.Trim, "trim", c.Trim, "Trim saved states to the bounding box of their non-empty cells, plus -trim-margin.")
+	fs.IntVar(&c.TrimMargin, "trim-margin", c.TrimMargin, "Empty cells kept around the non-empty cells when trimming.")
+	fs.StringVar(&c.Probes, "probes", c.Probes, "Probe file. Defaults to the input file name with "+ProbeExt+" appended.")
+	fs.StringVar(&c.Regions, "regions", c.Regions, "Region file for population counts. Defaults to the input file name with "+RegionExt+" appended.")
+	fs.StringVar(&c.Breakpoints, "breakpoints", c.Breakpoints, "Breakpoint file. Defaults to the input file name with "+BreakpointExt+" appended.")
+	fs.IntVar(&c.ProbeHistory, "probe-history", c.ProbeHistory, "Maximum number of generations of probe samples to keep.")
+	fs.IntVar(&c.WaveformLength, "waveform-length", c.WaveformLength, "Number of generations shown in the waveform panel.")
+	fs.IntVar(&c.WaveformLane, "waveform-lane", c.WaveformLane, "Height of a waveform lane in pixels.")
+	fs.BoolVar(&c.CycleDetect, "cycle-detect", c.CycleDetect, "Detect repeating states while the simulation runs.")
+	fs.BoolVar(&c.CyclePause, "cycle-pause", c.CyclePause, "Pause the simulation when a repeating state is found.")
+	fs.IntVar(&c.CycleInterval, "cycle-interval", c.CycleInterval, "Compare the states of every Nth generation when detecting cycles.")
+	fs.IntVar(&c.CycleLimit, "cycle-limit", c.CycleLimit, "Maximum number of states remembered by cycle detection.")
+	fs.BoolVar(&c.Population, "population", c.Population, "Count the cells in each state every generation.")
+	fs.IntVar(&c.PopulationHistory, "population-history", c.PopulationHistory, "Maximum number of generations of population counts to keep.")
+	fs.BoolVar(&c.Heatmap, "heatmap", c.Heatmap, "Show the heatmap of electron activity.")
+	fs.IntVar(&c.HeatWindow, "heat-window", c.HeatWindow, "Generations counted in the heatmap, as a sliding window. 0 counts all of them.")
+	fs.IntVar(&c.Rewind, "rewind", c.Rewind, "Number of recent generations kept for stepping back.")
+	fs.IntVar(&c.RewindCheckpoints, "rewind-checkpoints", c.RewindCheckpoints, "Number of checkpoints kept for stepping back further.")
+	fs.IntVar(&c.RewindInterval, "rewind-interval", c.RewindInterval, "Generations between two rewind checkpoints.")
+	fs.IntVar(&c.StepBack, "step-back", c.StepBack, "Generations to go back with the rewind action.")
+	fs.StringVar(&c.RecordFormat, "record-format", c.RecordFormat, "Animation format for recordings: gif or apng.")
+	fs.IntVar(&c.RecordEvery, "record-every", c.RecordEvery, "Capture every Nth generation while recording.")
+	fs.IntVar(&c.RecordFrames, "record-frames", c.RecordFrames, "Maximum number of frames in a recording.")
+	fs.IntVar(&c.RecordScale, "record-scale", c.RecordScale, "Maximum scale factor for recorded frames.")
+	fs.IntVar(&c.RecordDelay, "record-delay", c.RecordDelay, "Delay between animation frames in milliseconds.")
+	fs.BoolVar(&c.RecordViewport, "record-viewport", c.RecordViewport, "Record only the visible part of the simulation, at its current zoom.")
+	fs.StringVar(&opt.config, "config", "", "Configuration file to load. Defaults to "+filepath.Join("<user config dir>", AppName, ConfigFile)+".")
+	fs.BoolVar(&opt.printConfig, "print-config", false, "Writes the effective configuration to stdout and exits.")
+	fs.BoolVar(&opt.version, "version", false, "Displays version information.")
+	return fs, &opt
+}
+
+// defaultConfigFile returns the path to the configuration file in the
+// user's configuration directory. On Linux, this is $XDG_CONFIG_HOME,
+// or ~/.config if that is not set.
+func defaultConfigFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
 	}
+	return filepath.Join(dir, AppName, ConfigFile), nil
+}
 
-	if len(*palEmpty) > 0 {
-		c.Palette.Empty = parseHex(*palEmpty)
+// Load reads settings from the given JSON file. Settings which do not
+// appear in the file keep their current values. Key bindings are merged
+// with the current ones by mergeKeys.
+func (c *Config) Load(file string) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
 	}
 
-	if len(*palWire) > 0 {
-		c.Palette.Wire = parseHex(*palWire)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	// Decoding into the current map would add the file's bindings to it,
+	// so they are read into an empty one instead.
+	keys := c.Keys
+	c.Keys = nil
+
+	err = dec.Decode(c)
+	c.Keys = mergeKeys(keys, c.Keys)
+
+	if err != nil {
+		return fmt.Errorf("%s: %v", file, err)
 	}
 
-	if len(*palHead) > 0 {
-		c.Palette.Head = parseHex(*palHead)
+	return nil
+}
+
+// mergeKeys returns the bindings in keys, overridden by those in override.
+// An action listed in override loses all its bindings from keys. Inputs
+// which override binds are taken away from the other actions in keys, so
+// one action can be moved onto another's input without rebinding both.
+// Conflicts within override itself are left for Validate to report.
+func mergeKeys(keys, override map[string][]string) map[string][]string {
+	used := make(map[Binding]bool)
+	for _, list := range override {
+		for _, str := range list {
+			if b, err := ParseBinding(str); err == nil {
+				used[b] = true
+			}
+		}
 	}
 
-	if len(*palTail) > 0 {
-		c.Palette.Tail = parseHex(*palTail)
+	out := make(map[string][]string, len(keys)+len(override))
+	for name, list := range keys {
+		if _, ok := override[name]; ok {
+			continue
+		}
+
+		kept := []string{}
+		for _, str := range list {
+			if b, err := ParseBinding(str); err != nil || !used[b] {
+				kept = append(kept, str)
+			}
+		}
+		out[name] = kept
 	}
 
-	return &c
+	for name, list := range override {
+		out[name] = list
+	}
+
+	return out
+}
+
+// Write writes the config to w as JSON.
+func (c *Config) Write(w io.Writer) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// Validate returns an error if any of the settings are invalid.
+func (c *Config) Validate() error {
+	switch {
+	case c.Width <= 0:
+		return errors.New("width must be > 0")
+	case c.Height <= 0:
+		return errors.New("height must be > 0")
+	case c.Zoom < MinZoom || c.Zoom > MaxZoom:
+		return fmt.Errorf("zoom must be in the range [%d, %d]", MinZoom, MaxZoom)
+	case c.Speed <= 0:
+		return errors.New("speed must be > 0")
+	case c.History < 0:
+		return errors.New("history must be >= 0")
+	case c.Grow <= 0:
+		return errors.New("grow must be > 0")
+	case c.TrimMargin < 0:
+		return errors.New("trim-margin must be >= 0")
+	case c.ProbeHistory <= 0:
+		return errors.New("probe-history must be > 0")
+	case c.WaveformLength <= 0:
+		return errors.New("waveform-length must be > 0")
+	case c.WaveformLane <= 0:
+		return errors.New("waveform-lane must be > 0")
+	case c.CycleInterval <= 0 || c.CycleLimit <= 0:
+		return errors.New("cycle-interval and cycle-limit must be > 0")
+	case c.PopulationHistory <= 0:
+		return errors.New("population-history must be > 0")
+	case c.HeatWindow < 0:
+		return errors.New("heat-window must be >= 0")
+	case c.Rewind < 0 || c.RewindCheckpoints < 0:
+		return errors.New("rewind and rewind-checkpoints must be >= 0")
+	case c.RewindInterval <= 0 || c.StepBack <= 0:
+		return errors.New("rewind-interval and step-back must be > 0")
+	case c.RecordFormat != FormatGIF && c.RecordFormat != FormatAPNG:
+		return fmt.Errorf("record-format must be %q or %q", FormatGIF, FormatAPNG)
+	case c.RecordEvery <= 0 || c.RecordFrames <= 0 || c.RecordScale <= 0:
+		return errors.New("record-every, record-frames and record-scale must be > 0")
+	case c.RecordDelay < 10:
+		return errors.New("record-delay must be >= 10")
+	}
+
+	// Check the bindings against a map with all known action names.
+	m := NewActionMap()
+	for name := range defaultKeys() {
+		m.Register(&Action{Name: name})
+	}
+
+	if err := m.Load(c.Keys); err != nil {
+		return fmt.Errorf("keys: %v", err)
+	}
+
+	return nil
+}
+
+// paletteJSON is the representation of a palette in configuration files.
+type paletteJSON struct {
+	Empty string `json:"empty"`
+	Wire  string `json:"wire"`
+	Head  string `json:"head"`
+	Tail  string `json:"tail"`
+}
+
+// MarshalJSON encodes the palette with colors in hex notation.
+func (p Palette) MarshalJSON() ([]byte, error) {
+	return json.Marshal(paletteJSON{
+		Empty: hexStr(p.Empty),
+		Wire:  hexStr(p.Wire),
+		Head:  hexStr(p.Head),
+		Tail:  hexStr(p.Tail),
+	})
+}
+
+// UnmarshalJSON decodes a palette with colors in hex notation.
+// Colors which are omitted keep their current value.
+func (p *Palette) UnmarshalJSON(data []byte) error {
+	var pj paletteJSON
+	if err := json.Unmarshal(data, &pj); err != nil {
+		return err
+	}
+
+	for _, v := range []struct {
+		str string
+		dst *color.RGBA
+	}{
+		{pj.Empty, &p.Empty},
+		{pj.Wire, &p.Wire},
+		{pj.Head, &p.Head},
+		{pj.Tail, &p.Tail},
+	} {
+		if err := (colorValue{v.dst}).Set(v.str); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// paletteFlags registers flags for each of the palette colors with fs.
+func paletteFlags(fs *flag.FlagSet, pal *Palette) {
+	fs.Var(colorValue{&pal.Empty}, "pal-empty", "Color for empty cells.")
+	fs.Var(colorValue{&pal.Wire}, "pal-wire", "Color for wire cells.")
+	fs.Var(colorValue{&pal.Head}, "pal-head", "Color for electron head cells.")
+	fs.Var(colorValue{&pal.Tail}, "pal-tail", "Color for electron tail cells.")
+}
+
+// colorValue implements flag.Value for colors in hex notation.
+type colorValue struct {
+	c *color.RGBA
+}
+
+func (v colorValue) String() string {
+	if v.c == nil {
+		return ""
+	}
+	return hexStr(*v.c)
+}
+
+func (v colorValue) Set(str string) error {
+	if len(str) == 0 {
+		return nil
+	}
+
+	c, err := parseHex(str)
+	if err != nil {
+		return err
+	}
+
+	*v.c = c
+	return nil
+}
+
+// zoomValue implements flag.Value for zoom levels.
+type zoomValue struct {
+	z *float32
+}
+
+func (v zoomValue) String() string {
+	if v.z == nil {
+		return ""
+	}
+	return strconv.FormatFloat(float64(*v.z), 'g', -1, 32)
+}
+
+func (v zoomValue) Set(str string) error {
+	z, err := strconv.ParseFloat(str, 32)
+	if err != nil {
+		return err
+	}
+
+	*v.z = float32(z)
+	return nil
 }
 
 // hexStr returns color c as a hex string.
@@ -94,37 +538,33 @@ func hexStr(clr color.RGBA) string {
 	return fmt.Sprintf("%02x%02x%02x", clr.R, clr.G, clr.B)
 }
 
-// parseHex returns the given hex string as a floating-point RGBA color.
+// parseHex returns the given hex string as an RGBA color.
 // E.g.: "ffffff" -> [255, 255, 255]
 // E.g.: "ff007f" -> [255, 0, 127]
-func parseHex(str string) color.RGBA {
+func parseHex(str string) (color.RGBA, error) {
 	str = strings.ToLower(str)
 	if len(str) != 6 {
-		fmt.Fprintf(os.Stderr, "invalid color %q; expected form: rrggbb\n", str)
-		os.Exit(1)
+		return color.RGBA{}, fmt.Errorf("invalid color %q; expected form: rrggbb", str)
 	}
 
 	sr := str[0:2]
 	sg := str[2:4]
 	sb := str[4:6]
 
-	r, err := strconv.ParseInt(sr, 16, 32)
+	r, err := strconv.ParseUint(sr, 16, 8)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "invalid red component in color %q; %v\n", str, err)
-		os.Exit(1)
+		return color.RGBA{}, fmt.Errorf("invalid red component in color %q; %v", str, err)
 	}
 
-	g, err := strconv.ParseInt(sg, 16, 32)
+	g, err := strconv.ParseUint(sg, 16, 8)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "invalid green component in color %q; %v\n", str, err)
-		os.Exit(1)
+		return color.RGBA{}, fmt.Errorf("invalid green component in color %q; %v", str, err)
 	}
 
-	b, err := strconv.ParseInt(sb, 16, 32)
+	b, err := strconv.ParseUint(sb, 16, 8)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "invalid blue component in color %q; %v\n", str, err)
-		os.Exit(1)
+		return color.RGBA{}, fmt.Errorf("invalid blue component in color %q; %v", str, err)
 	}
 
-	return color.RGBA{byte(r), byte(g), byte(b), 255}
+	return color.RGBA{byte(r), byte(g), byte(b), 255}, nil
 }
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestConfigLoadKeys(t *testing.T) {
+	file := filepath.Join(t.TempDir(), ConfigFile)
+	data := `{
+		"keys": {
+			"speed-up": ["Up"],
+			"speed-down": ["Down"],
+			"pan": ["Space", "MouseRight"]
+		}
+	}`
+
+	if err := ioutil.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := defaultConfig()
+	if err := c.Load(file); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	want := map[string][]string{
+		"speed-up":       {"Up"},
+		"speed-down":     {"Down"},
+		"pan":            {"Space", "MouseRight"},
+		"next-component": {},
+		"prev-component": {},
+		"cancel":         {"Backspace"},
+		"quit":           {"Escape"},
+	}
+
+	for name, list := range want {
+		if got := c.Keys[name]; !reflect.DeepEqual(got, list) {
+			t.Errorf("%s: got %q, want %q", name, got, list)
+		}
+	}
+}
+
+func TestConfigLoadKeyConflict(t *testing.T) {
+	file := filepath.Join(t.TempDir(), ConfigFile)
+	data := `{"keys": {"speed-up": ["Up"], "speed-down": ["Up"]}}`
+
+	if err := ioutil.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := defaultConfig()
+	if err := c.Load(file); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Validate(); err == nil {
+		t.Fatal("expected an error for an input bound twice in the file")
+	}
+}
+
+func TestConfigLoadNoKeys(t *testing.T) {
+	file := filepath.Join(t.TempDir(), ConfigFile)
+	if err := ioutil.WriteFile(file, []byte(`{"speed": 10}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := defaultConfig()
+	if err := c.Load(file); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(c.Keys, defaultKeys()) {
+		t.Error("keys differ from the defaults")
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Output formats for the convert command.
+const (
+	ConvertPNG = "png"
+	ConvertRLE = "rle"
+)
+
+var convertCommand = &Command{
+	Name:  "convert",
+	Usage: "<file>",
+	Brief: "Converts a circuit to PNG or RLE, optionally after simulating it.",
+	Run:   runConvert,
+}
+
+func runConvert(cmd *Command, args []string) error {
+	var pal Palette
+	pal.LoadDefault()
+
+	fs := newFlagSet(cmd)
+	paletteFlags(fs, &pal)
+	output := fs.String("o", StdStream, "Output file, or - for stdout.")
+	format := fs.String("format", "", "Output format: png or rle. Defaults to the output file extension, or png.")
+	steps := fs.Int("steps", 0, "Number of generations to simulate before writing the output.")
+	compact := fs.Bool("compact", false, "Write PNG output at 2 bits per pixel, dropping annotation colors.")
+	trim := fs.Bool("trim", false, "Trim the output to the bounding box of its non-empty cells, plus -margin.")
+	margin := fs.Int("margin", 4, "Empty cells kept around the non-empty cells when trimming.")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("missing input file")
+	}
+
+	if err := checkOutputFormat(*output, format); err != nil {
+		return err
+	}
+
+	if *steps < 0 {
+		return errors.New("steps must be >= 0")
+	}
+
+	if *margin < 0 {
+		return errors.New("margin must be >= 0")
+	}
+
+	pix, size, ann, err := loadCells(fs.Arg(0), &pal)
+	if err != nil {
+		return err
+	}
+
+	g := &Grid{pix, int(size[0]), int(size[1])}
+	g.Step(*steps)
+
+	if *compact {
+		ann = nil
+	}
+
+	if r, ok := g.TrimBounds(*margin); *trim && ok {
+		g = g.Crop(r)
+		if ann != nil {
+			ann = ann.Crop(r)
+		}
+	}
+
+	return writeGrid(*output, *format, g, &pal, ann)
+}
+
+// checkOutputFormat checks that format names a supported circuit output
+// format. If it is empty, it is set based on the extension of the output
+// file, defaulting to PNG.
+func checkOutputFormat(output string, format *string) error {
+	if len(*format) == 0 {
+		*format = ConvertPNG
+		if strings.EqualFold(filepath.Ext(output), "."+ConvertRLE) {
+			*format = ConvertRLE
+		}
+	}
+
+	if *format != ConvertPNG && *format != ConvertRLE {
+		return fmt.Errorf("format must be %q or %q", ConvertPNG, ConvertRLE)
+	}
+
+	return nil
+}
+
+// writeGrid writes the cells in g to the given file, or stdout if it is
+// StdStream. PNG output is drawn with the given palette and annotations.
+func writeGrid(file, format string, g *Grid, pal *Palette, ann *Annotations) error {
+	w, err := createOutput(file)
+	if err != nil {
+		return err
+	}
+
+	if format == ConvertRLE {
+		err = writeRLE(w, g)
+	} else {
+		err = writeSnapshot(w, pal.fromInternalFormat(g.Pix, g.Size(), ann))
+	}
+
+	if err != nil {
+		w.Close()
+		return err
+	}
+
+	return w.Close()
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+)
+
+// CycleKind defines what a CycleDetector found.
+type CycleKind int
+
+// Known cycle kinds.
+const (
+	CycleNone      CycleKind = iota // Nothing found yet.
+	CycleQuiescent                  // There are no heads or tails left.
+	CycleRepeat                     // The state repeats.
+)
+
+// Cycle describes a repeating simulation state.
+type Cycle struct {
+	Kind   CycleKind
+	Start  uint64 // First generation of the cycle.
+	Period uint64 // Number of generations after which the state repeats.
+}
+
+func (c Cycle) String() string {
+	switch c.Kind {
+	case CycleQuiescent:
+		return fmt.Sprintf("quiescent from generation %d", c.Start)
+	case CycleRepeat:
+		return fmt.Sprintf("period %d from generation %d", c.Period, c.Start)
+	default:
+		return "no cycle"
+	}
+}
+
+// CycleDetector finds repeating simulation states, from hashes of the
+// states of consecutive generations. States are compared every interval
+// generations. With an interval above 1, the reported period is the
+// smallest multiple of the interval after which the state repeats, and the
+// start of the cycle is rounded up to a multiple of the interval.
+type CycleDetector struct {
+	interval uint64
+	limit    int
+	first    uint64         // Generation of hashes[0].
+	hashes   []uint64       // Hashes of consecutive compared generations.
+	seen     map[uint64]int // Index of the last occurrence of each hash.
+	cycle    Cycle
+}
+
+// NewCycleDetector creates a detector which compares states every interval
+// generations. It remembers at most limit states. When that limit is
+// reached, the oldest half is forgotten.
+func NewCycleDetector(interval uint64, limit int) *CycleDetector {
+	if interval < 1 {
+		interval = 1
+	}
+
+	return &CycleDetector{
+		interval: interval,
+		limit:    limit,
+		seen:     make(map[uint64]int),
+	}
+}
+
+// Reset forgets all states and the cycle found so far.
+func (d *CycleDetector) Reset() {
+	d.hashes = d.hashes[:0]
+	d.seen = make(map[uint64]int)
+	d.cycle = Cycle{}
+}
+
+// Cycle returns the cycle found so far. Its kind is CycleNone if there
+// is none.
+func (d *CycleDetector) Cycle() Cycle {
+	return d.cycle
+}
+
+// Add records the hash of the state in the given generation, along with
+// the number of heads and tails in it. It returns true if this completes
+// a cycle. Once a cycle is found, further states are ignored until the
+// detector is reset. If gen does not follow the previous generation, the
+// detector is reset first.
+func (d *CycleDetector) Add(gen, hash uint64, active int) bool {
+	if d.cycle.Kind != CycleNone {
+		return false
+	}
+
+	if len(d.hashes) > 0 && gen != d.first+uint64(len(d.hashes))*d.interval {
+		d.Reset()
+	}
+
+	if len(d.hashes) == 0 {
+		d.first = gen
+	}
+
+	if active == 0 {
+		// Without heads or tails, nothing changes any more.
+		d.cycle = Cycle{CycleQuiescent, gen, 1}
+		return true
+	}
+
+	if i, ok := d.seen[hash]; ok {
+		period := uint64(len(d.hashes)-i) * d.interval
+
+		// Walk back to where the cycle starts. The states before the
+		// first occurrence match those before this one, for as long as
+		// the cycle has been running.
+		j := len(d.hashes)
+		for i > 0 && d.hashes[i-1] == d.hashes[j-1] {
+			i--
+			j--
+		}
+
+		d.cycle = Cycle{CycleRepeat, d.first + uint64(i)*d.interval, period}
+		return true
+	}
+
+	d.seen[hash] = len(d.hashes)
+	d.hashes = append(d.hashes, hash)
+
+	if d.limit > 0 && len(d.hashes) > d.limit {
+		d.forget(len(d.hashes) / 2)
+	}
+
+	return false
+}
+
+// forget drops the n oldest states.
+func (d *CycleDetector) forget(n int) {
+	d.hashes = append(d.hashes[:0], d.hashes[n:]...)
+	d.first += uint64(n) * d.interval
+
+	d.seen = make(map[uint64]int, len(d.hashes))
+	for i, h := range d.hashes {
+		d.seen[h] = i
+	}
+}
+
+var periodCommand = &Command{
+	Name:  "period",
+	Usage: "<file>",
+	Brief: "Simulates a circuit until its state repeats and prints the period.",
+	Run:   runPeriod,
+}
+
+func runPeriod(cmd *Command, args []string) error {
+	var pal Palette
+	pal.LoadDefault()
+
+	fs := newFlagSet(cmd)
+	paletteFlags(fs, &pal)
+	output := fs.String("o", StdStream, "Output file, or - for stdout.")
+	steps := fs.Int("steps", 100000, "Maximum number of generations to simulate.")
+	every := fs.Int("every", 1, "Compare the states of every Nth generation.")
+	limit := fs.Int("limit", 1000000, "Maximum number of states to remember.")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("missing input file")
+	}
+
+	if *steps < 0 {
+		return errors.New("steps must be >= 0")
+	}
+
+	if *every <= 0 || *limit <= 0 {
+		return errors.New("every and limit must be > 0")
+	}
+
+	g, err := LoadGrid(fs.Arg(0), &pal)
+	if err != nil {
+		return err
+	}
+
+	d := NewCycleDetector(uint64(*every), *limit)
+	h := fnv.New64a()
+
+	for gen := 0; gen <= *steps; gen += *every {
+		h.Reset()
+		h.Write(g.Pix)
+		p := g.Population()
+
+		if d.Add(uint64(gen), h.Sum64(), p.Head+p.Tail) {
+			break
+		}
+		g.Step(*every)
+	}
+
+	w, err := createOutput(*output)
+	if err != nil {
+		return err
+	}
+
+	if c := d.Cycle(); c.Kind != CycleNone {
+		fmt.Fprintln(w, c)
+	} else {
+		fmt.Fprintf(w, "no cycle within %d generations\n", *steps)
+	}
+	return w.Close()
+}
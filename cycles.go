@@ -0,0 +1,71 @@
+package main
+
+import "log"
+
+// setCycleDetection enables or disables the detection of repeating states.
+func (a *Application) setCycleDetection(enabled bool) {
+	if a.cycles != nil {
+		a.cycles.Release()
+		a.cycles = nil
+	}
+
+	if enabled {
+		c := a.config
+		h, err := NewHashSampler(uint64(c.CycleInterval), c.CycleLimit)
+		if err != nil {
+			log.Println("failed to enable cycle detection:", err)
+		} else {
+			a.cycles = h
+		}
+	}
+
+	a.updateSamplers()
+}
+
+// toggleCycles enables or disables the detection of repeating states.
+func (a *Application) toggleCycles() {
+	a.setCycleDetection(a.cycles == nil)
+
+	if a.cycles != nil {
+		log.Println("cycle detection enabled")
+	} else {
+		log.Println("cycle detection disabled")
+	}
+}
+
+// resetCycles forgets the states compared so far, because the simulation
+// no longer follows from them.
+func (a *Application) resetCycles() {
+	if a.cycles != nil {
+		a.cycles.Reset()
+	}
+}
+
+// checkCycles reports a cycle found since the last call, and pauses the
+// simulation if so configured.
+func (a *Application) checkCycles() {
+	if a.cycles == nil || !a.cycles.Found() {
+		return
+	}
+
+	log.Println("found a repeating state:", a.cycles.Detector().Cycle())
+
+	if a.config.CyclePause && a.running {
+		a.running = false
+		a.stopRecording()
+	}
+}
+
+// cycleState describes the cycle found so far, for the title bar.
+// Returns an empty string if there is none.
+func (a *Application) cycleState() string {
+	if a.cycles == nil {
+		return ""
+	}
+
+	c := a.cycles.Detector().Cycle()
+	if c.Kind == CycleNone {
+		return ""
+	}
+	return c.String()
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+// Overlay colors for the dead wire report.
+var (
+	UnreachableColor = color.RGBA{0xff, 0x90, 0x20, 0xd0} // Wires no electron can reach.
+	DeadWireColor    = color.RGBA{0xff, 0x20, 0x60, 0xd0} // Wires which carried no electron.
+)
+
+// WireSegment is a connected set of conductor cells.
+type WireSegment struct {
+	Bounds image.Rectangle // Bounding box of the cells.
+	Cells  []image.Point
+}
+
+// WireReport lists the wires of a circuit which never carry an electron.
+type WireReport struct {
+	// Unreachable lists the connected conductors which hold no electron
+	// head. Electrons only travel along conductors, so none can ever
+	// reach these.
+	Unreachable []WireSegment
+
+	// Dead lists the parts of the other conductors which were never an
+	// electron head while the circuit was simulated.
+	Dead []WireSegment
+}
+
+// Len returns the number of segments in the report.
+func (r *WireReport) Len() int {
+	return len(r.Unreachable) + len(r.Dead)
+}
+
+// FindDeadWires finds the wires in g which never carry an electron. The
+// active function reports whether the conductor cell at the given index
+// into g.Pix was an electron head while the circuit was simulated. If it
+// is nil, only unreachable wires are found. Segments with fewer than min
+// cells are left out.
+//
+// Cells connect through all eight of their neighbours, wrapping around the
+// grid edges, like the simulation rules do.
+func FindDeadWires(g *Grid, active func(i int) bool, min int) *WireReport {
+	var r WireReport
+
+	conductor := func(i int) bool {
+		return g.Pix[i] != CellEmpty
+	}
+
+	for _, seg := range g.segments(conductor) {
+		reachable := false
+		for _, i := range seg {
+			if g.Pix[i] == CellHead {
+				reachable = true
+				break
+			}
+		}
+
+		if !reachable && len(seg) >= min {
+			r.Unreachable = append(r.Unreachable, g.wireSegment(seg))
+		}
+	}
+
+	if active == nil {
+		return &r
+	}
+
+	// Only cells in reachable conductors are dead; the others were
+	// reported as unreachable.
+	unreachable := make([]bool, len(g.Pix))
+	for _, seg := range r.Unreachable {
+		for _, p := range seg.Cells {
+			unreachable[p.Y*g.Width+p.X] = true
+		}
+	}
+
+	dead := func(i int) bool {
+		return conductor(i) && !unreachable[i] && !active(i)
+	}
+
+	for _, seg := range g.segments(dead) {
+		if len(seg) >= min {
+			r.Dead = append(r.Dead, g.wireSegment(seg))
+		}
+	}
+
+	return &r
+}
+
+// segments returns the 8-connected sets of cells for which include returns
+// true, as indices into g.Pix. Connections wrap around the grid edges.
+func (g *Grid) segments(include func(i int) bool) [][]int {
+	var out [][]int
+	w, h := g.Width, g.Height
+	seen := make([]bool, len(g.Pix))
+
+	for start := range g.Pix {
+		if seen[start] || !include(start) {
+			continue
+		}
+
+		var seg []int
+		seen[start] = true
+		stack := []int{start}
+
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			seg = append(seg, i)
+
+			x, y := i%w, i/w
+			for _, d := range neighbours8 {
+				n := ((y+d.Y+h)%h)*w + (x+d.X+w)%w
+				if !seen[n] && include(n) {
+					seen[n] = true
+					stack = append(stack, n)
+				}
+			}
+		}
+
+		out = append(out, seg)
+	}
+
+	return out
+}
+
+// wireSegment turns the given cell indices into a segment.
+func (g *Grid) wireSegment(cells []int) WireSegment {
+	var s WireSegment
+	s.Cells = make([]image.Point, len(cells))
+
+	for i, c := range cells {
+		p := image.Pt(c%g.Width, c/g.Width)
+		s.Cells[i] = p
+		s.Bounds = s.Bounds.Union(image.Rectangle{p, p.Add(image.Pt(1, 1))})
+	}
+
+	return s
+}
+
+// Write writes the report to w, one segment per line. Each line holds the
+// kind of segment, the position, width and height of its bounding box and
+// its number of cells.
+func (r *WireReport) Write(w io.Writer) error {
+	write := func(kind string, segs []WireSegment) error {
+		for _, s := range segs {
+			b := s.Bounds
+			if _, err := fmt.Fprintf(w, "%s %d %d %d %d %d\n", kind, b.Min.X, b.Min.Y, b.Dx(), b.Dy(), len(s.Cells)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := write("unreachable", r.Unreachable); err != nil {
+		return err
+	}
+	return write("dead", r.Dead)
+}
+
+// Image draws the report over the cells of g. The circuit is dimmed and
+// the reported cells are highlighted.
+func (r *WireReport) Image(g *Grid, pal *Palette) *image.RGBA {
+	img := pal.dimmedImage(g)
+	for _, s := range r.Unreachable {
+		markCells(img, s.Cells, UnreachableColor)
+	}
+	for _, s := range r.Dead {
+		markCells(img, s.Cells, DeadWireColor)
+	}
+	return img
+}
+
+var deadWiresCommand = &Command{
+	Name:  "deadwires",
+	Usage: "<file>",
+	Brief: "Lists the wires of a circuit which never carry an electron.",
+	Run:   runDeadWires,
+}
+
+func runDeadWires(cmd *Command, args []string) error {
+	var pal Palette
+	pal.LoadDefault()
+
+	fs := newFlagSet(cmd)
+	paletteFlags(fs, &pal)
+	output := fs.String("o", StdStream, "Output file for the list of wires, or - for stdout.")
+	imageFile := fs.String("image", "", "Also write the circuit with the wires highlighted to this PNG file.")
+	steps := fs.Int("steps", 1000, "Number of generations to simulate. 0 only finds unreachable wires.")
+	min := fs.Int("min", 1, "Leave out segments with fewer cells.")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("missing input file")
+	}
+
+	if *steps < 0 || *min < 1 {
+		return errors.New("steps must be >= 0 and min must be > 0")
+	}
+
+	g, err := LoadGrid(fs.Arg(0), &pal)
+	if err != nil {
+		return err
+	}
+
+	var active func(i int) bool
+	if *steps > 0 {
+		heads := make([]bool, len(g.Pix))
+		sim := g.Clone()
+		for gen := 0; ; gen++ {
+			for i, cell := range sim.Pix {
+				if cell == CellHead {
+					heads[i] = true
+				}
+			}
+
+			if gen == *steps {
+				break
+			}
+			sim.Step(1)
+		}
+
+		active = func(i int) bool { return heads[i] }
+	}
+
+	report := FindDeadWires(g, active, *min)
+
+	w, err := createOutput(*output)
+	if err != nil {
+		return err
+	}
+
+	if err = report.Write(w); err != nil {
+		w.Close()
+		return err
+	}
+
+	if err = w.Close(); err != nil {
+		return err
+	}
+
+	if len(*imageFile) == 0 {
+		return nil
+	}
+
+	fd, err := createOutput(*imageFile)
+	if err != nil {
+		return err
+	}
+
+	if err = png.Encode(fd, report.Image(g, &pal)); err != nil {
+		fd.Close()
+		return err
+	}
+	return fd.Close()
+}
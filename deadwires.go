@@ -0,0 +1,69 @@
+package main
+
+import "log"
+
+// toggleDeadWires finds the wires which never carry an electron and marks
+// them in the overlay, or clears the marks if they are shown. While the
+// heatmap is shown, its counts tell which wires were active. Otherwise,
+// only unreachable wires are found.
+//
+// Whether a wire is reachable depends on the electrons the circuit starts
+// with, which may well have died out by now. So the search runs on the
+// state the simulation was loaded or last edited in.
+func (a *Application) toggleDeadWires() {
+	if a.deadWires != nil {
+		a.deadWires = nil
+		a.updatePreview()
+		return
+	}
+
+	var g *Grid
+	if base, gen := a.rewind.Base(); base != nil {
+		size := base.Size()
+		g = NewGrid(int(size[0]), int(size[1]))
+
+		rb := base.ReadAsync()
+		copy(g.Pix, rb.Wait())
+		rb.Release()
+
+		log.Printf("looking for dead wires from generation %d", gen)
+	} else {
+		g = a.simulation.Cells(a.simulation.Bounds())
+	}
+
+	var active func(i int) bool
+	if a.heatmap != nil {
+		rates := a.heatmap.Rates()
+		active = func(i int) bool { return rates[i] > 0 }
+	} else {
+		log.Println("the heatmap is not shown; only looking for unreachable wires")
+	}
+
+	r := FindDeadWires(g, active, 1)
+	for _, s := range r.Unreachable {
+		log.Printf("unreachable wire at %v, %d cells", s.Bounds, len(s.Cells))
+	}
+	for _, s := range r.Dead {
+		log.Printf("dead wire at %v, %d cells", s.Bounds, len(s.Cells))
+	}
+	log.Printf("found %d unreachable and %d dead wires", len(r.Unreachable), len(r.Dead))
+
+	if r.Len() > 0 {
+		a.deadWires = r
+	}
+	a.updatePreview()
+}
+
+// drawDeadWires marks the wires found by toggleDeadWires in the overlay.
+func (a *Application) drawDeadWires() {
+	if a.deadWires == nil {
+		return
+	}
+
+	for _, s := range a.deadWires.Unreachable {
+		a.overlay.SetPoints(s.Cells, UnreachableColor)
+	}
+	for _, s := range a.deadWires.Dead {
+		a.overlay.SetPoints(s.Cells, DeadWireColor)
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"strconv"
+	"strings"
+)
+
+// ShortestPath returns the shortest path along conductor cells from a to
+// b, including both. Its length minus one is the least number of
+// generations an electron needs to get from a to b, since it moves one cell
+// per generation. Cells connect through all eight of their neighbours,
+// wrapping around the grid edges, like the simulation rules do. Returns nil
+// if either cell does not conduct, or b can not be reached.
+func (g *Grid) ShortestPath(a, b image.Point) []image.Point {
+	w, h := g.Width, g.Height
+	bounds := g.Bounds()
+
+	if !a.In(bounds) || !b.In(bounds) || g.At(a.X, a.Y) == CellEmpty || g.At(b.X, b.Y) == CellEmpty {
+		return nil
+	}
+
+	// Breadth first search, remembering where each cell was reached from.
+	start, end := a.Y*w+a.X, b.Y*w+b.X
+	from := make([]int, len(g.Pix))
+	for i := range from {
+		from[i] = -1
+	}
+	from[start] = start
+	queue := []int{start}
+
+	for len(queue) > 0 && from[end] < 0 {
+		i := queue[0]
+		queue = queue[1:]
+
+		x, y := i%w, i/w
+		for _, d := range neighbours8 {
+			n := ((y+d.Y+h)%h)*w + (x+d.X+w)%w
+			if from[n] < 0 && g.Pix[n] != CellEmpty {
+				from[n] = i
+				queue = append(queue, n)
+			}
+		}
+	}
+
+	if from[end] < 0 {
+		return nil
+	}
+
+	var path []image.Point
+	for i := end; ; i = from[i] {
+		path = append(path, image.Pt(i%w, i/w))
+		if i == start {
+			break
+		}
+	}
+
+	// The path was collected from b back to a.
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// ArrivalTime measures how many generations an electron needs to get from
+// a to b. It turns all electrons in a copy of g into wire, places a head on
+// a and simulates until b becomes a head. The head has no tail, so it
+// spreads in all directions. Unlike the shortest path, this takes diodes
+// and gates into account. Returns false if b is not reached within max
+// generations.
+func (g *Grid) ArrivalTime(a, b image.Point, max int) (int, bool) {
+	bounds := g.Bounds()
+	if !a.In(bounds) || !b.In(bounds) || g.At(a.X, a.Y) == CellEmpty {
+		return 0, false
+	}
+
+	sim := g.Clone()
+	for i, cell := range sim.Pix {
+		if cell != CellEmpty {
+			sim.Pix[i] = CellWire
+		}
+	}
+	sim.Set(a.X, a.Y, CellHead)
+
+	for gen := 0; gen <= max; gen++ {
+		if sim.At(b.X, b.Y) == CellHead {
+			return gen, true
+		}
+		sim.Step(1)
+	}
+
+	return 0, false
+}
+
+// formatDelay formats a number of generations, along with its remainder
+// modulo the given clock period, if that is above 0.
+func formatDelay(n, period int) string {
+	if period > 0 {
+		return fmt.Sprintf("%d generations (%d mod %d)", n, n%period, period)
+	}
+	return fmt.Sprintf("%d generations", n)
+}
+
+// parseCell parses a cell given as x,y, or as the name of one of the
+// given probes.
+func parseCell(s string, probes []Probe) (image.Point, error) {
+	if i := findProbeName(probes, s); i >= 0 {
+		return image.Pt(probes[i].X, probes[i].Y), nil
+	}
+
+	fields := strings.Split(s, ",")
+	if len(fields) != 2 {
+		return image.Point{}, fmt.Errorf("invalid cell %q; expected x,y or a probe name", s)
+	}
+
+	x, err := strconv.Atoi(strings.TrimSpace(fields[0]))
+	if err != nil {
+		return image.Point{}, fmt.Errorf("invalid cell %q; %v", s, err)
+	}
+
+	y, err := strconv.Atoi(strings.TrimSpace(fields[1]))
+	if err != nil {
+		return image.Point{}, fmt.Errorf("invalid cell %q; %v", s, err)
+	}
+
+	return image.Pt(x, y), nil
+}
+
+var delayCommand = &Command{
+	Name:  "delay",
+	Usage: "<file>",
+	Brief: "Measures the propagation delay between two cells of a circuit.",
+	Run:   runDelay,
+}
+
+func runDelay(cmd *Command, args []string) error {
+	var pal Palette
+	pal.LoadDefault()
+
+	fs := newFlagSet(cmd)
+	paletteFlags(fs, &pal)
+	output := fs.String("o", StdStream, "Output file, or - for stdout.")
+	fromCell := fs.String("from", "", "Cell the signal starts at, as x,y or the name of a probe.")
+	toCell := fs.String("to", "", "Cell the signal arrives at, as x,y or the name of a probe.")
+	probeFile := fs.String("probes", "", "Probe file. Defaults to the input file name with "+ProbeExt+" appended, if it exists.")
+	steps := fs.Int("steps", DelaySteps, "Maximum number of generations to simulate when measuring the arrival time.")
+	period := fs.Int("period", 0, "Clock period. If set, delays are also given modulo the period.")
+	showPath := fs.Bool("path", false, "Also list the cells of the shortest path.")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("missing input file")
+	}
+
+	if len(*fromCell) == 0 || len(*toCell) == 0 {
+		return errors.New("from and to must be given")
+	}
+
+	if *steps < 0 || *period < 0 {
+		return errors.New("steps and period must be >= 0")
+	}
+
+	probes, err := loadProbesFor(fs.Arg(0), *probeFile)
+	if err != nil {
+		return err
+	}
+
+	a, err := parseCell(*fromCell, probes)
+	if err != nil {
+		return err
+	}
+
+	b, err := parseCell(*toCell, probes)
+	if err != nil {
+		return err
+	}
+
+	g, err := LoadGrid(fs.Arg(0), &pal)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range []image.Point{a, b} {
+		if !p.In(g.Bounds()) || g.At(p.X, p.Y) == CellEmpty {
+			return fmt.Errorf("cell %d,%d is not part of a wire", p.X, p.Y)
+		}
+	}
+
+	w, err := createOutput(*output)
+	if err != nil {
+		return err
+	}
+
+	path := g.ShortestPath(a, b)
+	if path == nil {
+		fmt.Fprintln(w, "static: unreachable")
+	} else {
+		fmt.Fprintln(w, "static:", formatDelay(len(path)-1, *period))
+	}
+
+	if n, ok := g.ArrivalTime(a, b, *steps); ok {
+		fmt.Fprintln(w, "dynamic:", formatDelay(n, *period))
+	} else {
+		fmt.Fprintf(w, "dynamic: no arrival within %d generations\n", *steps)
+	}
+
+	if *showPath {
+		for _, p := range path {
+			fmt.Fprintf(w, "%d,%d\n", p.X, p.Y)
+		}
+	}
+
+	return w.Close()
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"log"
+)
+
+// Overlay colors of the delay tool.
+var (
+	DelayPathColor = color.RGBA{0xff, 0x90, 0x20, 0xa0} // Shortest path between the cells.
+	DelayEndColor  = color.RGBA{0xff, 0xff, 0xff, 0xe0} // The cells the path starts and ends at.
+)
+
+// DelaySteps is the number of generations after which the measured
+// arrival time is given up on.
+const DelaySteps = 10000
+
+// measureDelay picks the cells between which the propagation delay is
+// measured. The first click picks the start, the second the end. The
+// length of the shortest path is logged right away and the path is marked
+// in the overlay. The arrival time is measured on a copy of the current
+// state in the background and logged when done. Delays are also given
+// modulo the period found by the cycle detection, if it found one.
+func (a *Application) measureDelay(cell image.Point) {
+	g := a.simulation.Cells(a.simulation.Bounds())
+	if !cell.In(g.Bounds()) || g.At(cell.X, cell.Y) == CellEmpty {
+		log.Printf("cell %d,%d is not part of a wire", cell.X, cell.Y)
+		return
+	}
+
+	if !a.delayPicked || a.delayPath != nil {
+		a.delayStart = cell
+		a.delayPicked = true
+		a.delayPath = nil
+		log.Printf("measuring delay from %d,%d; click the cell the signal arrives at", cell.X, cell.Y)
+		a.updatePreview()
+		return
+	}
+
+	from := a.delayStart
+	period := a.clockPeriod()
+
+	a.delayPath = g.ShortestPath(from, cell)
+	if a.delayPath == nil {
+		a.delayPicked = false
+		log.Printf("delay %d,%d to %d,%d: static: unreachable", from.X, from.Y, cell.X, cell.Y)
+		a.updatePreview()
+		return
+	}
+
+	log.Printf("delay %d,%d to %d,%d: static: %s", from.X, from.Y, cell.X, cell.Y, formatDelay(len(a.delayPath)-1, period))
+	a.updatePreview()
+
+	go func() {
+		if n, ok := g.ArrivalTime(from, cell, DelaySteps); ok {
+			log.Printf("delay %d,%d to %d,%d: dynamic: %s", from.X, from.Y, cell.X, cell.Y, formatDelay(n, period))
+		} else {
+			log.Printf("delay %d,%d to %d,%d: dynamic: no arrival within %d generations", from.X, from.Y, cell.X, cell.Y, DelaySteps)
+		}
+	}()
+}
+
+// clockPeriod returns the period of the repeating state found by the
+// cycle detection. Returns 0 if none was found.
+func (a *Application) clockPeriod() int {
+	if a.cycles == nil {
+		return 0
+	}
+
+	c := a.cycles.Detector().Cycle()
+	if c.Kind != CycleRepeat {
+		return 0
+	}
+	return int(c.Period)
+}
+
+// delayChanged discards the cells picked by the delay tool, after the
+// conductors may have changed.
+func (a *Application) delayChanged() {
+	a.delayPicked = false
+	a.delayPath = nil
+}
+
+// drawDelay marks the cells picked by the delay tool and the shortest path
+// between them in the overlay.
+func (a *Application) drawDelay() {
+	if !a.delayPicked {
+		return
+	}
+
+	a.overlay.SetPoints(a.delayPath, DelayPathColor)
+	a.overlay.Set(a.delayStart.X, a.delayStart.Y, DelayEndColor)
+
+	if n := len(a.delayPath); n > 0 {
+		end := a.delayPath[n-1]
+		a.overlay.Set(end.X, end.Y, DelayEndColor)
+	}
+}
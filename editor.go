@@ -0,0 +1,144 @@
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/hexaflex/wireworld-gpu/math"
+)
+
+// Brush size limits for the editor, in cells.
+const (
+	MinBrushSize = 1
+	MaxBrushSize = 64
+)
+
+// Overlay colors for editor markers.
+var (
+	SelectionColor = color.RGBA{0x40, 0x90, 0xff, 0xc0} // Selection outline.
+	InputPinColor  = color.RGBA{0x20, 0xff, 0x40, 0xe0} // Input pins of components.
+	OutputPinColor = color.RGBA{0xff, 0x40, 0x20, 0xe0} // Output pins of components.
+	ProbeColor     = color.RGBA{0xff, 0x30, 0xff, 0xe0} // Probed cells.
+)
+
+// Tool defines a drawing tool of the editor.
+type Tool int
+
+// Known tools.
+const (
+	ToolBrush      Tool = iota // Paint with the brush while the mouse is held.
+	ToolLine                   // Draw a line from where the mouse is pressed to where it is released.
+	ToolRect                   // Draw the outline of a rectangle between press and release.
+	ToolFilledRect             // Draw a filled rectangle between press and release.
+	ToolFill                   // Flood fill the region under the cursor.
+	ToolSelect                 // Select the rectangle between press and release.
+	ToolProbe                  // Add or remove a probe on the cell under the cursor.
+	ToolBreakpoint             // Add a breakpoint for the region between press and release.
+	ToolDelay                  // Measure the propagation delay between two clicked cells.
+)
+
+func (t Tool) String() string {
+	switch t {
+	case ToolLine:
+		return "line"
+	case ToolRect:
+		return "rectangle"
+	case ToolFilledRect:
+		return "filled rectangle"
+	case ToolFill:
+		return "fill"
+	case ToolSelect:
+		return "select"
+	case ToolProbe:
+		return "probe"
+	case ToolBreakpoint:
+		return "breakpoint"
+	case ToolDelay:
+		return "delay"
+	default:
+		return "brush"
+	}
+}
+
+// Editor holds the state of the in-app cell editor. While editing is
+// enabled, cells can be painted directly into the running simulation.
+type Editor struct {
+	Enabled bool          // Is edit mode enabled?
+	Cell    byte          // Cell state to paint with.
+	Brush   int           // Width and height of the brush, in cells.
+	Tool    Tool          // Active drawing tool.
+	Drawing bool          // Is the paint action being held?
+	Anchor  image.Point   // Cell where the paint action started, or was last applied by the brush.
+	Region  []image.Point // Cells selected by the fill tool, while drawing.
+
+	// Selection is the region selected with the select tool.
+	// It is empty if nothing is selected.
+	Selection image.Rectangle
+
+	// Paste holds the cells being pasted, if any. While it is set, the
+	// paint action places these cells instead of using the active tool.
+	Paste *Grid
+
+	// Component is the library component being pasted, if any. Its cells
+	// are the ones in Paste. It is transformed along with them.
+	Component *Component
+}
+
+// NewEditor creates a new editor which paints wire with a 1 cell brush.
+func NewEditor() *Editor {
+	return &Editor{
+		Cell:  CellWire,
+		Brush: MinBrushSize,
+	}
+}
+
+// SetBrush sets the brush size, clamped to the valid range.
+func (e *Editor) SetBrush(size int) {
+	e.Brush = int(math.Clamp(float32(size), MinBrushSize, MaxBrushSize))
+}
+
+// BrushRect returns the cells covered by the brush, when centered on the
+// given cell.
+func (e *Editor) BrushRect(cell image.Point) image.Rectangle {
+	min := cell.Sub(image.Pt(e.Brush/2, e.Brush/2))
+	return image.Rectangle{min, min.Add(image.Pt(e.Brush, e.Brush))}
+}
+
+// Shape returns the cells covered by the line or rectangle tools, when drawn
+// from the anchor to the given cell. Returns nil for other tools.
+func (e *Editor) Shape(cell image.Point) []image.Point {
+	switch e.Tool {
+	case ToolLine:
+		return linePoints(e.Anchor, cell)
+	case ToolRect:
+		return rectPoints(e.Anchor, cell, false)
+	case ToolFilledRect:
+		return rectPoints(e.Anchor, cell, true)
+	}
+	return nil
+}
+
+// PasteRect returns the region covered by the cells being pasted, when
+// centered on the given cell.
+func (e *Editor) PasteRect(cell image.Point) image.Rectangle {
+	if e.Paste == nil {
+		return image.Rectangle{}
+	}
+
+	min := cell.Sub(image.Pt(e.Paste.Width/2, e.Paste.Height/2))
+	return image.Rectangle{min, min.Add(image.Pt(e.Paste.Width, e.Paste.Height))}
+}
+
+// cellName returns a human readable name for the given cell state.
+func cellName(cell byte) string {
+	switch cell {
+	case CellWire:
+		return "wire"
+	case CellHead:
+		return "head"
+	case CellTail:
+		return "tail"
+	default:
+		return "empty"
+	}
+}
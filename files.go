@@ -0,0 +1,50 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// StdStream is the file name which refers to stdin or stdout.
+const StdStream = "-"
+
+// stdinData caches the contents of stdin. This allows an input read from
+// stdin to be loaded more than once, as happens when the viewer reloads it.
+var stdinData []byte
+
+// readInput returns the contents of the given file.
+// If file is StdStream, this reads from stdin.
+func readInput(file string) ([]byte, error) {
+	if file != StdStream {
+		return ioutil.ReadFile(file)
+	}
+
+	if stdinData == nil {
+		data, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		stdinData = data
+	}
+
+	return stdinData, nil
+}
+
+// createOutput creates the given file for writing.
+// If file is StdStream or empty, this writes to stdout.
+func createOutput(file string) (io.WriteCloser, error) {
+	if len(file) == 0 || file == StdStream {
+		return nopCloser{os.Stdout}, nil
+	}
+	return os.Create(file)
+}
+
+// nopCloser wraps a writer which should not be closed, like stdout.
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/go-gl/gl/v4.2-core/gl"
+	"github.com/hexaflex/wireworld-gpu/math"
+)
+
+// gatherRows defines the maximum number of generations gathered into the
+// texture of a Gatherer before it is read back.
+const gatherRows = 256
+
+// Sampler gathers data from the simulation state after every generation.
+type Sampler interface {
+	// Expects returns true if the given generation directly follows the
+	// last gathered one.
+	Expects(generation uint64) bool
+
+	// Gather records data from the given state, which holds the given
+	// generation.
+	Gather(state *SimulationState, generation uint64)
+}
+
+// GatherFormat defines the texel format of a Gatherer's texture.
+type GatherFormat struct {
+	Internal int32  // Internal texture format.
+	Format   uint32 // Pixel format for reading.
+	Type     uint32 // Pixel type for reading.
+	Size     int    // Bytes per texel.
+}
+
+// Texel formats for gatherers.
+var (
+	GatherByte  = GatherFormat{gl.R8, gl.RED, gl.UNSIGNED_BYTE, 1}
+	GatherUint4 = GatherFormat{gl.RGBA32UI, gl.RGBA_INTEGER, gl.UNSIGNED_INT, 16}
+)
+
+// Gatherer runs a shader pass after every generation, which draws one point
+// per texel into a row of a small texture. The shader reads the simulation
+// state from texture unit 0. Its Target uniform holds the texture width and
+// height and the row being drawn.
+//
+// Once all rows are filled, or Flush is called, the texture is read back
+// asynchronously. The rows are handed to a callback from Poll, once the data
+// has arrived. This does not stall the GPU.
+//
+// By default, every generation is gathered. With an interval of n, only
+// every nth generation is, and consecutive rows are n generations apart.
+type Gatherer struct {
+	shader   Shader
+	format   GatherFormat
+	width    int
+	pending  []gatherBatch
+	done     func(first uint64, data []byte)
+	first    uint64 // Generation of the first row in the texture.
+	rows     int    // Number of filled rows.
+	interval uint64 // Generations between two rows.
+	started  bool   // Has anything been gathered yet?
+	fbo      uint32
+	tex      uint32
+	vao      uint32
+	vbo      uint32
+}
+
+// gatherBatch is a pending read of a Gatherer's texture.
+type gatherBatch struct {
+	readback *Readback
+	first    uint64
+}
+
+// NewGatherer creates a gatherer which draws the points in verts with the
+// given shader. Each vertex consists of float attributes with the given
+// sizes, bound to consecutive locations. The done function receives the
+// gathered rows of consecutive gathered generations, starting at the given
+// one.
+func NewGatherer(src ShaderSource, verts []float32, attribs []int, format GatherFormat, done func(first uint64, data []byte)) (*Gatherer, error) {
+	var err error
+	var g Gatherer
+
+	stride := 0
+	for _, n := range attribs {
+		stride += n
+	}
+
+	if stride == 0 || len(verts) == 0 || len(verts)%stride != 0 {
+		return nil, errors.New("gatherer: invalid vertex data")
+	}
+
+	g.format = format
+	g.width = len(verts) / stride
+	g.done = done
+	g.interval = 1
+
+	g.shader, err = src.Compile()
+	if err != nil {
+		return nil, err
+	}
+
+	gl.GenTextures(1, &g.tex)
+	gl.BindTexture(gl.TEXTURE_2D, g.tex)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, format.Internal, int32(g.width), gatherRows, 0, format.Format, format.Type, nil)
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+
+	gl.GenFramebuffers(1, &g.fbo)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, g.fbo)
+	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, g.tex, 0)
+	status := gl.CheckFramebufferStatus(gl.FRAMEBUFFER)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+
+	if status != gl.FRAMEBUFFER_COMPLETE {
+		g.Release()
+		return nil, errors.New("gatherer: incomplete framebuffer")
+	}
+
+	gl.GenVertexArrays(1, &g.vao)
+	gl.BindVertexArray(g.vao)
+
+	gl.GenBuffers(1, &g.vbo)
+	gl.BindBuffer(gl.ARRAY_BUFFER, g.vbo)
+
+	offset := 0
+	for i, n := range attribs {
+		gl.EnableVertexAttribArray(uint32(i))
+		gl.VertexAttribPointer(uint32(i), int32(n), gl.FLOAT, false, int32(stride*4), gl.PtrOffset(offset*4))
+		offset += n
+	}
+
+	gl.BufferData(gl.ARRAY_BUFFER, len(verts)*4, gl.Ptr(verts), gl.STATIC_DRAW)
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
+	gl.BindVertexArray(0)
+	return &g, nil
+}
+
+// Release cleans up resources. Pending reads are discarded.
+func (g *Gatherer) Release() {
+	g.Discard()
+
+	gl.DeleteBuffers(1, &g.vbo)
+	gl.DeleteVertexArrays(1, &g.vao)
+	gl.DeleteFramebuffers(1, &g.fbo)
+	gl.DeleteTextures(1, &g.tex)
+	g.shader.Release()
+}
+
+// Width returns the number of texels in a row.
+func (g *Gatherer) Width() int {
+	return g.width
+}
+
+// Interval returns the number of generations between two gathered rows.
+func (g *Gatherer) Interval() uint64 {
+	return g.interval
+}
+
+// SetInterval sets the number of generations between two gathered rows.
+// Generations in between are skipped by Gather.
+func (g *Gatherer) SetInterval(n uint64) {
+	if n < 1 {
+		n = 1
+	}
+	g.interval = n
+}
+
+// Next returns the generation the gatherer expects to gather next.
+func (g *Gatherer) Next() uint64 {
+	return g.first + uint64(g.rows)*g.interval
+}
+
+// Expects returns true if the given generation directly follows the last
+// gathered one, or falls in between two gathered generations.
+func (g *Gatherer) Expects(generation uint64) bool {
+	return generation%g.interval != 0 || (g.started && generation == g.Next())
+}
+
+// Gather runs the shader on the given state, which holds the given
+// generation. If this does not follow the previously gathered generation,
+// the gathered rows are flushed and a new batch is started.
+func (g *Gatherer) Gather(state *SimulationState, generation uint64) {
+	if generation%g.interval != 0 {
+		return
+	}
+
+	if g.rows > 0 && generation != g.Next() {
+		g.Flush()
+	}
+
+	if g.rows == 0 {
+		g.first = generation
+	}
+	g.started = true
+
+	g.shader.Use()
+	g.shader.SetUniformVec3("Target", math.Vec3{float32(g.width), gatherRows, float32(g.rows)})
+
+	gl.Viewport(0, 0, int32(g.width), gatherRows)
+	gl.BindVertexArray(g.vao)
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, g.fbo)
+	state.BindTexture()
+
+	gl.DrawArrays(gl.POINTS, 0, int32(g.width))
+
+	state.UnbindTexture()
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+	gl.BindVertexArray(0)
+	g.shader.Unuse()
+
+	g.rows++
+	if g.rows == gatherRows {
+		g.Flush()
+	}
+}
+
+// Flush starts reading back the gathered rows.
+func (g *Gatherer) Flush() {
+	if g.rows == 0 {
+		return
+	}
+
+	f := g.format
+	rb := newReadback(g.fbo, 0, 0, int32(g.width), int32(g.rows), f.Format, f.Type, f.Size)
+	g.pending = append(g.pending, gatherBatch{rb, g.first})
+	g.first = g.Next()
+	g.rows = 0
+}
+
+// Poll hands the rows of completed reads to the callback, in the order
+// they were gathered. This does not block.
+func (g *Gatherer) Poll() {
+	n := 0
+	for _, b := range g.pending {
+		if !b.readback.Ready() {
+			break
+		}
+		g.done(b.first, b.readback.Data())
+		b.readback.Release()
+		n++
+	}
+	g.pending = append(g.pending[:0], g.pending[n:]...)
+}
+
+// Discard drops the gathered rows and any reads still pending, without
+// waiting for them. None of them reach the callback. The next gathered
+// generation starts a new batch.
+func (g *Gatherer) Discard() {
+	for _, b := range g.pending {
+		b.readback.Release()
+	}
+	g.pending = g.pending[:0]
+	g.rows = 0
+	g.started = false
+}
+
+// Sync flushes the gathered rows and waits until all of them have been
+// handed to the callback.
+func (g *Gatherer) Sync() {
+	g.Flush()
+	for _, b := range g.pending {
+		g.done(b.first, b.readback.Wait())
+		b.readback.Release()
+	}
+	g.pending = g.pending[:0]
+}
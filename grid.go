@@ -0,0 +1,210 @@
+package main
+
+import (
+	"image"
+
+	"github.com/hexaflex/wireworld-gpu/math"
+)
+
+// Grid holds simulation state in CPU memory, using the internal 8bpp cell
+// format. It implements the same rules as the simulation shader, so it can
+// be used where no GPU is available, or where the cells need inspecting.
+type Grid struct {
+	Pix    []byte // Cell states, row by row, starting at the top-left.
+	Width  int    // Width in cells.
+	Height int    // Height in cells.
+}
+
+// NewGrid creates a new, empty grid of the given dimensions.
+func NewGrid(width, height int) *Grid {
+	return &Grid{
+		Pix:    make([]byte, width*height),
+		Width:  width,
+		Height: height,
+	}
+}
+
+// LoadGrid loads a grid from the given file. Supports the same formats as
+// LoadSimulation. It uses the given color palette to recognize cell states.
+func LoadGrid(file string, pal *Palette) (*Grid, error) {
+	pix, size, _, err := loadCells(file, pal)
+	if err != nil {
+		return nil, err
+	}
+	return &Grid{pix, int(size[0]), int(size[1])}, nil
+}
+
+// Size returns the grid dimensions.
+func (g *Grid) Size() math.Vec2 {
+	return math.Vec2{float32(g.Width), float32(g.Height)}
+}
+
+// Bounds returns the grid dimensions as a rectangle.
+func (g *Grid) Bounds() image.Rectangle {
+	return image.Rect(0, 0, g.Width, g.Height)
+}
+
+// At returns the cell state at the given position.
+// Returns CellEmpty for positions outside the grid.
+func (g *Grid) At(x, y int) byte {
+	if x < 0 || y < 0 || x >= g.Width || y >= g.Height {
+		return CellEmpty
+	}
+	return g.Pix[y*g.Width+x]
+}
+
+// Set sets the cell state at the given position.
+// Positions outside the grid are ignored.
+func (g *Grid) Set(x, y int, cell byte) {
+	if x < 0 || y < 0 || x >= g.Width || y >= g.Height {
+		return
+	}
+	g.Pix[y*g.Width+x] = cell
+}
+
+// Clone returns a copy of the grid.
+func (g *Grid) Clone() *Grid {
+	c := NewGrid(g.Width, g.Height)
+	copy(c.Pix, g.Pix)
+	return c
+}
+
+// Fill sets all cells to the given state.
+func (g *Grid) Fill(cell byte) {
+	for i := range g.Pix {
+		g.Pix[i] = cell
+	}
+}
+
+// Crop returns a copy of the cells in the given rectangle.
+// Parts of r outside the grid are empty.
+func (g *Grid) Crop(r image.Rectangle) *Grid {
+	out := NewGrid(r.Dx(), r.Dy())
+	for y := 0; y < out.Height; y++ {
+		for x := 0; x < out.Width; x++ {
+			out.Pix[y*out.Width+x] = g.At(r.Min.X+x, r.Min.Y+y)
+		}
+	}
+	return out
+}
+
+// ContentBounds returns the smallest rectangle which contains all non-empty
+// cells. Returns an empty rectangle if all cells are empty.
+func (g *Grid) ContentBounds() image.Rectangle {
+	var r image.Rectangle
+	for y := 0; y < g.Height; y++ {
+		row := g.Pix[y*g.Width : (y+1)*g.Width]
+		for x, cell := range row {
+			if cell != CellEmpty {
+				r = r.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return r
+}
+
+// TrimBounds returns the bounds of the grid after trimming it to its
+// non-empty cells, with the given margin of empty cells around them.
+// Returns false if all cells are empty.
+func (g *Grid) TrimBounds(margin int) (image.Rectangle, bool) {
+	r := g.ContentBounds()
+	if r.Empty() {
+		return r, false
+	}
+	return r.Inset(-margin), true
+}
+
+// Blit copies the cells of src into the grid, with the top-left corner of
+// src placed at pos. Cells which fall outside the grid are ignored.
+func (g *Grid) Blit(pos image.Point, src *Grid) {
+	r := src.Bounds().Add(pos).Intersect(g.Bounds())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		row := src.Pix[(y-pos.Y)*src.Width:]
+		copy(g.Pix[y*g.Width+r.Min.X:y*g.Width+r.Max.X], row[r.Min.X-pos.X:])
+	}
+}
+
+// Rotate returns a copy of the grid, rotated 90 degrees clockwise.
+func (g *Grid) Rotate() *Grid {
+	out := NewGrid(g.Height, g.Width)
+	for y := 0; y < g.Height; y++ {
+		for x := 0; x < g.Width; x++ {
+			out.Pix[x*out.Width+(out.Width-1-y)] = g.Pix[y*g.Width+x]
+		}
+	}
+	return out
+}
+
+// FlipH mirrors the grid horizontally, in place.
+func (g *Grid) FlipH() {
+	for y := 0; y < g.Height; y++ {
+		row := g.Pix[y*g.Width : (y+1)*g.Width]
+		for i, j := 0, len(row)-1; i < j; i, j = i+1, j-1 {
+			row[i], row[j] = row[j], row[i]
+		}
+	}
+}
+
+// FlipV mirrors the grid vertically, in place.
+func (g *Grid) FlipV() {
+	for i, j := 0, g.Height-1; i < j; i, j = i+1, j-1 {
+		a := g.Pix[i*g.Width : (i+1)*g.Width]
+		b := g.Pix[j*g.Width : (j+1)*g.Width]
+		for x := range a {
+			a[x], b[x] = b[x], a[x]
+		}
+	}
+}
+
+// Step runs the simulation n times.
+//
+// Like the simulation shader, this wraps around the grid edges.
+func (g *Grid) Step(n int) {
+	if n < 1 || len(g.Pix) == 0 {
+		return
+	}
+
+	w, h := g.Width, g.Height
+	next := make([]byte, len(g.Pix))
+
+	for ; n > 0; n-- {
+		for y := 0; y < h; y++ {
+			up := ((y + h - 1) % h) * w
+			row := y * w
+			down := ((y + 1) % h) * w
+
+			for x := 0; x < w; x++ {
+				cell := g.Pix[row+x]
+
+				switch cell {
+				case CellWire:
+					left := (x + w - 1) % w
+					right := (x + 1) % w
+
+					heads := 0
+					for _, i := range [8]int{
+						up + left, up + x, up + right,
+						row + left, row + right,
+						down + left, down + x, down + right,
+					} {
+						if g.Pix[i] == CellHead {
+							heads++
+						}
+					}
+
+					if heads == 1 || heads == 2 {
+						cell = CellHead
+					}
+				case CellHead:
+					cell = CellTail
+				case CellTail:
+					cell = CellWire
+				}
+
+				next[row+x] = cell
+			}
+		}
+
+		g.Pix, next = next, g.Pix
+	}
+}
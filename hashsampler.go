@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/binary"
+
+	"github.com/go-gl/gl/v4.2-core/gl"
+)
+
+// HashSampler hashes the simulation state on the GPU every few generations
+// and hands the hashes to a CycleDetector.
+//
+// The hash is computed by a reduction in two passes. The first hashes each
+// row of cells into a ReduceTarget with one texel per row. The second
+// combines the row hashes into a row of the gather texture, which is read
+// back in batches without stalling the GPU.
+type HashSampler struct {
+	*Gatherer
+	detector *CycleDetector
+	found    bool // Has the detector found a cycle since the last call to Found?
+	rows     Shader
+	target   ReduceTarget
+	vao      uint32
+}
+
+// NewHashSampler creates a sampler which hashes every interval generations.
+// The detector remembers at most limit states.
+func NewHashSampler(interval uint64, limit int) (*HashSampler, error) {
+	var err error
+	var h HashSampler
+
+	h.detector = NewCycleDetector(interval, limit)
+
+	h.rows, err = HashRowShader.Compile()
+	if err != nil {
+		return nil, err
+	}
+
+	h.Gatherer, err = NewGatherer(HashShader, []float32{0}, []int{1}, GatherUint4, h.process)
+	if err != nil {
+		h.rows.Release()
+		return nil, err
+	}
+
+	h.SetInterval(interval)
+
+	// The row shader generates its vertices.
+	gl.GenVertexArrays(1, &h.vao)
+	return &h, nil
+}
+
+// Release cleans up resources.
+func (h *HashSampler) Release() {
+	h.target.Release()
+	gl.DeleteVertexArrays(1, &h.vao)
+	h.rows.Release()
+	h.Gatherer.Release()
+}
+
+// Detector returns the cycle detector.
+func (h *HashSampler) Detector() *CycleDetector {
+	return h.detector
+}
+
+// Reset forgets all states compared so far. Hashes which are still being
+// read back are discarded, so they do not end up in the new comparison.
+func (h *HashSampler) Reset() {
+	h.Discard()
+	h.detector.Reset()
+	h.found = false
+}
+
+// Found returns true if the detector found a cycle since the last call.
+func (h *HashSampler) Found() bool {
+	found := h.found
+	h.found = false
+	return found
+}
+
+// Gather hashes the given state, if the generation is one of those being
+// compared.
+func (h *HashSampler) Gather(state *SimulationState, generation uint64) {
+	if generation%h.Interval() != 0 {
+		return
+	}
+
+	size := state.Size()
+	if err := h.target.Resize(1, int(size[1])); err != nil {
+		return
+	}
+
+	h.target.Draw(h.rows, h.vao, 3, state)
+	h.target.BindTexture()
+	h.Gatherer.Gather(state, generation)
+	h.target.UnbindTexture()
+}
+
+// process hands the hashes in the gathered rows to the detector.
+func (h *HashSampler) process(first uint64, data []byte) {
+	const size = 16
+
+	for row := 0; (row+1)*size <= len(data); row++ {
+		texel := data[row*size:]
+		hash := uint64(binary.LittleEndian.Uint32(texel))<<32 | uint64(binary.LittleEndian.Uint32(texel[4:]))
+		active := int(binary.LittleEndian.Uint32(texel[8:]))
+
+		if h.detector.Add(first+uint64(row)*h.Interval(), hash, active) {
+			h.found = true
+		}
+	}
+}
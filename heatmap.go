@@ -0,0 +1,270 @@
+package main
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+
+	"github.com/go-gl/gl/v4.2-core/gl"
+	"github.com/hexaflex/wireworld-gpu/math"
+)
+
+// HeatRate is the highest rate at which a cell can be an electron head.
+// A head turns into a tail and then into wire, before it can be a head again.
+const HeatRate = 1.0 / 3
+
+// Heatmap counts how often each cell was an electron head. It is updated
+// by the simulation pass itself, which writes the heads of each generation
+// into the heatmap's texture as a second render target. Blending adds them
+// to the counts so far.
+//
+// Counts are kept over a sliding window, or over all generations. The
+// sliding window is an exponential moving average: with every generation,
+// the counts so far decay by 1 - 1/window. The texture holds the weighted
+// sums, and the heatmap tracks the total weight, to turn them into rates.
+type Heatmap struct {
+	size   math.Vec2
+	decay  float32 // Factor applied to the counts so far with every generation.
+	weight float64 // Total weight of the generations counted so far.
+	fbo    uint32
+	tex    uint32
+}
+
+// NewHeatmap creates an empty heatmap with the given dimensions. It counts
+// over a sliding window of the given number of generations, or over all
+// generations if window is 0.
+func NewHeatmap(size math.Vec2, window int) (*Heatmap, error) {
+	if size[0] < 1 || size[1] < 1 {
+		return nil, errors.New("heatmap: invalid dimensions")
+	}
+
+	h := Heatmap{size: size, decay: 1}
+	if window > 0 {
+		h.decay = 1 - 1/float32(window)
+	}
+
+	gl.GenTextures(1, &h.tex)
+	gl.BindTexture(gl.TEXTURE_2D, h.tex)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.R32F, int32(size[0]), int32(size[1]), 0, gl.RED, gl.FLOAT, nil)
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+
+	gl.GenFramebuffers(1, &h.fbo)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, h.fbo)
+	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, h.tex, 0)
+	status := gl.CheckFramebufferStatus(gl.FRAMEBUFFER)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+
+	if status != gl.FRAMEBUFFER_COMPLETE {
+		h.Release()
+		return nil, errors.New("heatmap: incomplete framebuffer")
+	}
+
+	h.Clear()
+	return &h, nil
+}
+
+// Release clears the heatmap's resources.
+func (h *Heatmap) Release() {
+	gl.DeleteFramebuffers(1, &h.fbo)
+	gl.DeleteTextures(1, &h.tex)
+}
+
+// Size returns the heatmap dimensions.
+func (h *Heatmap) Size() math.Vec2 {
+	return h.size
+}
+
+// Clear resets all counts.
+func (h *Heatmap) Clear() {
+	var zero [4]float32
+	gl.BindFramebuffer(gl.FRAMEBUFFER, h.fbo)
+	gl.ClearBufferfv(gl.COLOR, 0, &zero[0])
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+	h.weight = 0
+}
+
+// Scale returns the factor which turns the values in the texture into
+// rates relative to HeatRate. Returns 0 if nothing has been counted yet.
+func (h *Heatmap) Scale() float32 {
+	if h.weight == 0 {
+		return 0
+	}
+	return float32(1 / (h.weight * HeatRate))
+}
+
+// Bind binds the heatmap texture.
+func (h *Heatmap) Bind() {
+	gl.BindTexture(gl.TEXTURE_2D, h.tex)
+}
+
+// Unbind unbinds the heatmap texture.
+func (h *Heatmap) Unbind() {
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+}
+
+// beginStep sets up blending for the simulation passes which add to the
+// heatmap. The cell states in draw buffer 0 are written as they are.
+func (h *Heatmap) beginStep() {
+	gl.BlendColor(0, 0, 0, h.decay)
+	gl.BlendFunci(0, gl.ONE, gl.ZERO)
+	gl.BlendFunci(1, gl.ONE, gl.CONSTANT_ALPHA)
+}
+
+// counted records that a simulation pass added a generation.
+func (h *Heatmap) counted() {
+	h.weight = h.weight*float64(h.decay) + 1
+}
+
+// endStep restores the default blending.
+func (h *Heatmap) endStep() {
+	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+}
+
+// Rates reads the heatmap and returns the rate at which each cell was a
+// head, relative to HeatRate. This waits for the GPU.
+func (h *Heatmap) Rates() []float32 {
+	pix := make([]float32, int(h.size[0])*int(h.size[1]))
+	gl.BindTexture(gl.TEXTURE_2D, h.tex)
+	gl.PixelStorei(gl.PACK_ALIGNMENT, 4)
+	gl.GetTexImage(gl.TEXTURE_2D, 0, gl.RED, gl.FLOAT, gl.Ptr(pix))
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+
+	scale := h.Scale()
+	for i := range pix {
+		pix[i] *= scale
+	}
+	return pix
+}
+
+// heatColor returns the color of the given relative rate, which runs
+// from blue for rarely active cells, through green and yellow, to red
+// for cells which are as busy as they can be.
+func heatColor(rate float32) color.RGBA {
+	rate = math.Clamp(rate, 0, 1)
+
+	stops := [...]color.RGBA{
+		{0x20, 0x40, 0xff, 0xff},
+		{0x20, 0xd0, 0xd0, 0xff},
+		{0x40, 0xe0, 0x40, 0xff},
+		{0xff, 0xe0, 0x20, 0xff},
+		{0xff, 0x20, 0x20, 0xff},
+	}
+
+	f := rate * float32(len(stops)-1)
+	i := int(f)
+	if i >= len(stops)-1 {
+		return stops[len(stops)-1]
+	}
+
+	t := f - float32(i)
+	a, b := stops[i], stops[i+1]
+	mix := func(a, b uint8) uint8 {
+		return uint8(float32(a) + (float32(b)-float32(a))*t + 0.5)
+	}
+	return color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), 0xff}
+}
+
+// heatImage draws the given rates over the cells of g, as the heatmap
+// display mode does. The circuit is dimmed, so cells which were never a
+// head stand out against active ones. Busier cells are drawn more opaque.
+func heatImage(g *Grid, rates []float32, pal *Palette) *image.RGBA {
+	img := pal.dimmedImage(g)
+
+	mix := func(a, b uint8, t float32) uint8 {
+		return uint8(float32(a) + (float32(b)-float32(a))*t + 0.5)
+	}
+
+	for i, rate := range rates {
+		if rate <= 0 {
+			continue
+		}
+
+		x, y := i%g.Width, i/g.Width
+		c := img.RGBAAt(x, y)
+		h := heatColor(rate)
+		a := heatAlpha(rate)
+		img.SetRGBA(x, y, color.RGBA{mix(c.R, h.R, a), mix(c.G, h.G, a), mix(c.B, h.B, a), 0xff})
+	}
+
+	return img
+}
+
+// heatAlpha returns the opacity of the heat color for the given rate.
+func heatAlpha(rate float32) float32 {
+	return 0.5 + 0.5*math.Clamp(rate, 0, 1)
+}
+
+var heatmapCommand = &Command{
+	Name:  "heatmap",
+	Usage: "<file>",
+	Brief: "Simulates a circuit and writes a PNG heatmap of its electron activity.",
+	Run:   runHeatmap,
+}
+
+func runHeatmap(cmd *Command, args []string) error {
+	var pal Palette
+	pal.LoadDefault()
+
+	fs := newFlagSet(cmd)
+	paletteFlags(fs, &pal)
+	output := fs.String("o", StdStream, "Output file, or - for stdout.")
+	steps := fs.Int("steps", 1000, "Number of generations to simulate.")
+	window := fs.Int("window", 0, "Generations counted, as a sliding window. 0 counts all of them.")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("missing input file")
+	}
+
+	if *steps < 1 || *window < 0 {
+		return errors.New("steps must be > 0 and window must be >= 0")
+	}
+
+	g, err := LoadGrid(fs.Arg(0), &pal)
+	if err != nil {
+		return err
+	}
+
+	// Count the heads like the simulation pass does, after every step.
+	decay := 1.0
+	if *window > 0 {
+		decay = 1 - 1/float64(*window)
+	}
+
+	heat := make([]float64, len(g.Pix))
+	weight := 0.0
+
+	for i := 0; i < *steps; i++ {
+		g.Step(1)
+		for j, cell := range g.Pix {
+			heat[j] *= decay
+			if cell == CellHead {
+				heat[j]++
+			}
+		}
+		weight = weight*decay + 1
+	}
+
+	rates := make([]float32, len(heat))
+	for i, h := range heat {
+		rates[i] = float32(h / (weight * HeatRate))
+	}
+
+	w, err := createOutput(*output)
+	if err != nil {
+		return err
+	}
+
+	if err = png.Encode(w, heatImage(g, rates, &pal)); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
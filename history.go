@@ -0,0 +1,321 @@
+package main
+
+import (
+	"errors"
+	"image"
+)
+
+// Change is a reversible modification of the application state, as
+// recorded in the undo history.
+type Change interface {
+	// Undo reverts the change.
+	Undo(a *Application) error
+
+	// Redo applies the change again, after it was undone.
+	Redo(a *Application) error
+
+	// Len returns the approximate number of bytes used by the change.
+	Len() int
+}
+
+// History records changes so they can be undone and redone. The memory
+// used by recorded changes is kept within a budget by dropping the oldest
+// ones.
+type History struct {
+	undo     []Change
+	redo     []Change
+	group    changeGroup
+	grouping bool
+	size     int
+	budget   int
+}
+
+// NewHistory creates a new, empty history which keeps at most budget bytes
+// worth of changes. A budget of 0 disables the history.
+func NewHistory(budget int) *History {
+	return &History{budget: budget}
+}
+
+// Enabled returns true if the history records changes.
+func (h *History) Enabled() bool {
+	return h.budget > 0
+}
+
+// Begin starts grouping changes. Until End is called, pushed changes are
+// combined into a single change, which is undone and redone as a whole.
+func (h *History) Begin() {
+	h.grouping = true
+}
+
+// End stops grouping changes and records the group, if it is not empty.
+func (h *History) End() {
+	if !h.grouping {
+		return
+	}
+
+	g := h.group
+	h.group = nil
+	h.grouping = false
+
+	// Edits which left all cells as they were are dropped.
+	var kept changeGroup
+	for _, c := range g {
+		if c.Len() > 0 {
+			kept = append(kept, c)
+		}
+	}
+
+	if len(kept) > 0 {
+		h.record(kept)
+	}
+}
+
+// Push records a change which has just been applied. Changes which were
+// undone can no longer be redone afterwards. Nil changes are ignored.
+// Changes whose Len turns out to be 0 are not recorded.
+func (h *History) Push(c Change) {
+	if c == nil || !h.Enabled() {
+		return
+	}
+
+	for _, r := range h.redo {
+		h.size -= r.Len()
+	}
+	h.redo = nil
+
+	if h.grouping {
+		h.group = append(h.group, c)
+		return
+	}
+
+	h.record(c)
+}
+
+// record adds c to the undo stack and drops the oldest changes until
+// the history fits its budget again. Empty changes are not recorded.
+func (h *History) record(c Change) {
+	if c.Len() == 0 {
+		return
+	}
+
+	h.undo = append(h.undo, c)
+	h.size += c.Len()
+	h.trim()
+}
+
+// trim drops the oldest changes until the history fits its budget.
+// Commands which can be redone are only dropped once nothing is left to
+// undo, starting with the one furthest away.
+func (h *History) trim() {
+	for h.size > h.budget && len(h.undo) > 0 {
+		h.size -= h.undo[0].Len()
+		h.undo[0] = nil
+		h.undo = h.undo[1:]
+	}
+
+	for h.size > h.budget && len(h.redo) > 0 {
+		h.size -= h.redo[0].Len()
+		h.redo[0] = nil
+		h.redo = h.redo[1:]
+	}
+}
+
+// Undo reverts the most recent change. Returns false if there was
+// nothing to undo. A change which fails to undo is dropped.
+func (h *History) Undo(a *Application) (bool, error) {
+	h.End()
+
+	if len(h.undo) == 0 {
+		return false, nil
+	}
+
+	c := h.undo[len(h.undo)-1]
+	h.undo = h.undo[:len(h.undo)-1]
+	h.size -= c.Len()
+
+	if err := c.Undo(a); err != nil {
+		return true, err
+	}
+
+	h.redo = append(h.redo, c)
+	h.size += c.Len()
+	h.trim()
+	return true, nil
+}
+
+// Redo applies the most recently undone change again. Returns false if
+// there was nothing to redo. A change which fails to redo is dropped.
+func (h *History) Redo(a *Application) (bool, error) {
+	h.End()
+
+	if len(h.redo) == 0 {
+		return false, nil
+	}
+
+	c := h.redo[len(h.redo)-1]
+	h.redo = h.redo[:len(h.redo)-1]
+	h.size -= c.Len()
+
+	if err := c.Redo(a); err != nil {
+		return true, err
+	}
+
+	h.undo = append(h.undo, c)
+	h.size += c.Len()
+	h.trim()
+	return true, nil
+}
+
+// changeGroup is a sequence of changes which are undone and redone
+// together, like all the edits in a single brush stroke.
+type changeGroup []Change
+
+func (g changeGroup) Undo(a *Application) error {
+	for i := len(g) - 1; i >= 0; i-- {
+		if err := g[i].Undo(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g changeGroup) Redo(a *Application) error {
+	for _, c := range g {
+		if err := c.Redo(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g changeGroup) Len() int {
+	var n int
+	for _, c := range g {
+		n += c.Len()
+	}
+	return n
+}
+
+// editChange records an edit of the cells in a region of the simulation.
+// Only the cells which actually changed are stored.
+//
+// The states before the edit are read back from the GPU asynchronously.
+// Until they are needed, the change holds the pending readback and the
+// written cells. That way, a brush stroke does not stall the pipeline for
+// every step. The comparison happens when the change is first measured,
+// undone or redone, which is usually when the stroke ends.
+type editChange struct {
+	region  image.Rectangle
+	index   []int32 // Offsets of the changed cells in the region.
+	before  []byte  // Cell states before the edit.
+	after   []byte  // Cell states after the edit.
+	pending *Readback
+	written *Grid  // Cells written by the edit, while pending.
+	mask    []bool // Cells of written which were written, or nil for all.
+}
+
+// newEditChange records an edit of a region. Its previous states are read
+// by before, which must have been started before the edit was applied. The
+// edit wrote the cells of after which are set in mask, or all of them if
+// mask is nil.
+func newEditChange(region image.Rectangle, before *Readback, after *Grid, mask []bool) *editChange {
+	return &editChange{
+		region:  region,
+		pending: before,
+		written: after,
+		mask:    mask,
+	}
+}
+
+// resolve waits for the previous states and keeps only the cells which
+// the edit actually changed.
+func (c *editChange) resolve() {
+	if c.pending == nil {
+		return
+	}
+
+	before := c.pending.Wait()
+	for i, cell := range c.written.Pix {
+		if (c.mask == nil || c.mask[i]) && before[i] != cell {
+			c.index = append(c.index, int32(i))
+			c.before = append(c.before, before[i])
+			c.after = append(c.after, cell)
+		}
+	}
+
+	c.pending.Release()
+	c.pending = nil
+	c.written = nil
+	c.mask = nil
+}
+
+func (c *editChange) Undo(a *Application) error { return c.apply(a, c.before) }
+func (c *editChange) Redo(a *Application) error { return c.apply(a, c.after) }
+
+func (c *editChange) Len() int {
+	c.resolve()
+	return len(c.index)*4 + len(c.before) + len(c.after)
+}
+
+// apply writes the given states into the changed cells. Other cells in the
+// region keep their current state, since the simulation may have evolved
+// them since the edit.
+func (c *editChange) apply(a *Application, states []byte) error {
+	c.resolve()
+
+	if !c.region.In(a.simulation.Bounds()) {
+		return errors.New("edited region is outside the simulation")
+	}
+
+	g := NewGrid(c.region.Dx(), c.region.Dy())
+	mask := make([]bool, len(g.Pix))
+	for i, idx := range c.index {
+		g.Pix[idx] = states[i]
+		mask[idx] = true
+	}
+
+	a.simulation.SetCellsMasked(c.region.Min, g, mask)
+	a.cellsChanged()
+	return nil
+}
+
+// replaceChange records the replacement of the simulation, like when
+// reloading the input file. It holds a snapshot of the other state: the
+// one before the replacement while it can be undone, and the one before
+// undoing it while it can be redone. A resize also moves the markers, so
+// those of the other state are swapped along, if they are set.
+type replaceChange struct {
+	snapshot *Snapshot
+	markers  *markers
+}
+
+func (c *replaceChange) Undo(a *Application) error { return c.swap(a) }
+func (c *replaceChange) Redo(a *Application) error { return c.swap(a) }
+
+func (c *replaceChange) Len() int {
+	return c.snapshot.Len()
+}
+
+// swap exchanges the current simulation with the snapshot.
+func (c *replaceChange) swap(a *Application) error {
+	cur, err := a.simulation.Snapshot()
+	if err != nil {
+		return err
+	}
+
+	sim, err := c.snapshot.Restore()
+	if err != nil {
+		return err
+	}
+
+	a.stopRecording()
+	a.setSimulation(sim)
+	c.snapshot = cur
+
+	if c.markers != nil {
+		m := a.currentMarkers()
+		a.setMarkers(c.markers)
+		c.markers = m
+	}
+	return nil
+}
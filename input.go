@@ -0,0 +1,344 @@
+package main
+
+import (
+	"fmt"
+	"math/bits"
+	"sort"
+	"strings"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// InputKind identifies the kind of input which triggers a binding.
+type InputKind int
+
+// Known input kinds.
+const (
+	InputKey    InputKind = iota // Code is a glfw.Key.
+	InputMouse                   // Code is a glfw.MouseButton.
+	InputScroll                  // Code is a scroll direction.
+)
+
+// Scroll directions, used as codes for InputScroll bindings.
+const (
+	ScrollUp = iota
+	ScrollDown
+	ScrollLeft
+	ScrollRight
+)
+
+// modifierMask selects the modifier keys which are relevant to bindings.
+// Lock keys are ignored.
+const modifierMask = glfw.ModShift | glfw.ModControl | glfw.ModAlt | glfw.ModSuper
+
+// Names used in binding strings for inputs other than keys.
+var (
+	modifierNames = []struct {
+		Name string
+		Mod  glfw.ModifierKey
+	}{
+		{"Ctrl", glfw.ModControl},
+		{"Shift", glfw.ModShift},
+		{"Alt", glfw.ModAlt},
+		{"Super", glfw.ModSuper},
+	}
+
+	mouseNames = map[string]glfw.MouseButton{
+		"MouseLeft":   glfw.MouseButtonLeft,
+		"MouseRight":  glfw.MouseButtonRight,
+		"MouseMiddle": glfw.MouseButtonMiddle,
+		"Mouse4":      glfw.MouseButton4,
+		"Mouse5":      glfw.MouseButton5,
+		"Mouse6":      glfw.MouseButton6,
+		"Mouse7":      glfw.MouseButton7,
+		"Mouse8":      glfw.MouseButton8,
+	}
+
+	scrollNames = map[string]int{
+		"ScrollUp":    ScrollUp,
+		"ScrollDown":  ScrollDown,
+		"ScrollLeft":  ScrollLeft,
+		"ScrollRight": ScrollRight,
+	}
+)
+
+// Binding defines an input which triggers an action.
+type Binding struct {
+	Kind InputKind        // Kind of input.
+	Code int              // Key, mouse button or scroll direction.
+	Mods glfw.ModifierKey // Modifier keys which must be held.
+}
+
+// ParseBinding parses a binding from its string form. This is a key, mouse
+// button or scroll direction name, optionally preceded by modifiers. For
+// example: "Q", "Ctrl+Z", "Shift+MouseLeft" or "Alt+ScrollUp". Names are
+// case insensitive.
+func ParseBinding(str string) (Binding, error) {
+	var b Binding
+
+	parts := strings.Split(str, "+")
+	for _, part := range parts[:len(parts)-1] {
+		mod, ok := parseModifier(strings.TrimSpace(part))
+		if !ok {
+			return b, fmt.Errorf("unknown modifier %q in %q", part, str)
+		}
+		b.Mods |= mod
+	}
+
+	name := strings.TrimSpace(parts[len(parts)-1])
+
+	for n, button := range mouseNames {
+		if strings.EqualFold(n, name) {
+			b.Kind, b.Code = InputMouse, int(button)
+			return b, nil
+		}
+	}
+
+	for n, dir := range scrollNames {
+		if strings.EqualFold(n, name) {
+			b.Kind, b.Code = InputScroll, dir
+			return b, nil
+		}
+	}
+
+	key, err := parseKey(name)
+	if err != nil {
+		return b, err
+	}
+
+	b.Kind, b.Code = InputKey, int(key)
+	return b, nil
+}
+
+// parseModifier returns the modifier key with the given name.
+func parseModifier(name string) (glfw.ModifierKey, bool) {
+	if strings.EqualFold(name, "Control") {
+		return glfw.ModControl, true
+	}
+
+	for _, m := range modifierNames {
+		if strings.EqualFold(m.Name, name) {
+			return m.Mod, true
+		}
+	}
+
+	return 0, false
+}
+
+// String returns the binding in the form accepted by ParseBinding.
+func (b Binding) String() string {
+	var sb strings.Builder
+
+	for _, m := range modifierNames {
+		if b.Mods&m.Mod != 0 {
+			sb.WriteString(m.Name)
+			sb.WriteByte('+')
+		}
+	}
+
+	switch b.Kind {
+	case InputMouse:
+		for n, button := range mouseNames {
+			if int(button) == b.Code {
+				sb.WriteString(n)
+			}
+		}
+	case InputScroll:
+		for n, dir := range scrollNames {
+			if dir == b.Code {
+				sb.WriteString(n)
+			}
+		}
+	default:
+		sb.WriteString(keyName(glfw.Key(b.Code)))
+	}
+
+	return sb.String()
+}
+
+// input returns the binding without its modifiers.
+func (b Binding) input() Binding {
+	return Binding{Kind: b.Kind, Code: b.Code}
+}
+
+// Action defines a named operation which is triggered through input
+// bindings. An action is either instant, in which case only Press is set,
+// or it is held for as long as its input is held down, in which case
+// Release is called once the input is let go.
+type Action struct {
+	Name        string // Name used in configuration files.
+	Description string // Short description of what the action does.
+	Press       func() // Called when a bound input is pressed.
+	Release     func() // Called when a bound input is released. Optional.
+}
+
+// ActionMap dispatches input events to actions, through their bindings.
+// It does not depend on a window, so actions can be triggered directly
+// by calling Press and Release.
+type ActionMap struct {
+	actions  map[string]*Action
+	bindings map[Binding]string
+	held     map[Binding]string
+	active   map[string]int
+}
+
+// NewActionMap creates a new, empty action map.
+func NewActionMap() *ActionMap {
+	return &ActionMap{
+		actions:  make(map[string]*Action),
+		bindings: make(map[Binding]string),
+		held:     make(map[Binding]string),
+		active:   make(map[string]int),
+	}
+}
+
+// Register adds the given action to the map.
+// An existing action with the same name is replaced.
+func (m *ActionMap) Register(a *Action) {
+	m.actions[a.Name] = a
+}
+
+// Action returns the action with the given name, or nil if it does not exist.
+func (m *ActionMap) Action(name string) *Action {
+	return m.actions[name]
+}
+
+// Actions returns all registered actions, sorted by name.
+func (m *ActionMap) Actions() []*Action {
+	out := make([]*Action, 0, len(m.actions))
+	for _, a := range m.actions {
+		out = append(out, a)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Name < out[j].Name
+	})
+
+	return out
+}
+
+// Bind binds the given input to an action. Returns an error if the action
+// does not exist, or if the input is already bound to another action.
+func (m *ActionMap) Bind(name string, b Binding) error {
+	if _, ok := m.actions[name]; !ok {
+		return fmt.Errorf("unknown action %q", name)
+	}
+
+	b.Mods &= modifierMask
+
+	if other, ok := m.bindings[b]; ok && other != name {
+		return fmt.Errorf("%q is bound to both %q and %q", b, other, name)
+	}
+
+	m.bindings[b] = name
+	return nil
+}
+
+// Load binds the inputs listed for each action. Inputs are in the form
+// accepted by ParseBinding. Existing bindings are cleared first.
+func (m *ActionMap) Load(bindings map[string][]string) error {
+	m.bindings = make(map[Binding]string)
+
+	for name, list := range bindings {
+		for _, str := range list {
+			b, err := ParseBinding(str)
+			if err != nil {
+				return fmt.Errorf("action %q: %v", name, err)
+			}
+
+			if err := m.Bind(name, b); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// lookup returns the action bound to the given input. Modifiers which are
+// held but not part of any binding are ignored, so Space still pans while
+// Shift is held. Of the bindings whose modifiers are all held, the one with
+// the most modifiers wins. Returns false if there is none, or if two
+// actions are equally specific.
+func (m *ActionMap) lookup(b Binding) (string, bool) {
+	var name string
+	best := -1
+	tie := false
+
+	// Walk all subsets of the held modifiers.
+	mods := b.Mods
+	for sub := mods; ; sub = (sub - 1) & mods {
+		in := Binding{Kind: b.Kind, Code: b.Code, Mods: sub}
+		if n, ok := m.bindings[in]; ok {
+			switch count := bits.OnesCount(uint(sub)); {
+			case count > best:
+				name, best, tie = n, count, false
+			case count == best && n != name:
+				tie = true
+			}
+		}
+
+		if sub == 0 {
+			break
+		}
+	}
+
+	return name, best >= 0 && !tie
+}
+
+// Press triggers the action bound to the given input, if any.
+// Returns true if an action was triggered.
+func (m *ActionMap) Press(b Binding) bool {
+	b.Mods &= modifierMask
+
+	name, ok := m.lookup(b)
+	if !ok {
+		return false
+	}
+
+	// Remember which action this input triggered, so the release goes to
+	// the same action, even if the modifiers were let go first.
+	in := b.input()
+	if _, ok := m.held[in]; ok {
+		return true
+	}
+
+	m.held[in] = name
+	m.active[name]++
+
+	if a := m.actions[name]; a.Press != nil {
+		a.Press()
+	}
+
+	return true
+}
+
+// Release releases the action held by the given input, if any.
+func (m *ActionMap) Release(b Binding) {
+	in := b.input()
+
+	name, ok := m.held[in]
+	if !ok {
+		return
+	}
+
+	delete(m.held, in)
+	m.active[name]--
+
+	if a := m.actions[name]; m.active[name] == 0 && a.Release != nil {
+		a.Release()
+	}
+}
+
+// Trigger presses and immediately releases the given input.
+// This is used for inputs which have no duration, like scrolling.
+func (m *ActionMap) Trigger(b Binding) bool {
+	ok := m.Press(b)
+	m.Release(b)
+	return ok
+}
+
+// Active returns true if the named action is currently held down.
+func (m *ActionMap) Active(name string) bool {
+	return m.active[name] > 0
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+func TestParseBinding(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Binding
+		str  string
+	}{
+		{"Q", Binding{InputKey, int(glfw.KeyQ), 0}, "Q"},
+		{"ctrl+z", Binding{InputKey, int(glfw.KeyZ), glfw.ModControl}, "Ctrl+Z"},
+		{"Control + Shift + D", Binding{InputKey, int(glfw.KeyD), glfw.ModControl | glfw.ModShift}, "Ctrl+Shift+D"},
+		{"Shift+MouseLeft", Binding{InputMouse, int(glfw.MouseButtonLeft), glfw.ModShift}, "Shift+MouseLeft"},
+		{"Alt+ScrollUp", Binding{InputScroll, ScrollUp, glfw.ModAlt}, "Alt+ScrollUp"},
+		{"F5", Binding{InputKey, int(glfw.KeyF5), 0}, "F5"},
+	}
+
+	for _, tt := range tests {
+		b, err := ParseBinding(tt.in)
+		if err != nil {
+			t.Errorf("%q: %v", tt.in, err)
+			continue
+		}
+
+		if b != tt.want {
+			t.Errorf("%q: got %+v, want %+v", tt.in, b, tt.want)
+		}
+
+		if s := b.String(); s != tt.str {
+			t.Errorf("%q: String() = %q, want %q", tt.in, s, tt.str)
+		}
+	}
+}
+
+func TestParseBindingErrors(t *testing.T) {
+	for _, in := range []string{"", "Hyper+Q", "NoSuchKey", "Ctrl+"} {
+		if _, err := ParseBinding(in); err == nil {
+			t.Errorf("%q: expected an error", in)
+		}
+	}
+}
+
+// testActions returns an action map with the given actions, which count
+// how often they are pressed and released.
+func testActions(names ...string) (*ActionMap, map[string]int, map[string]int) {
+	m := NewActionMap()
+	pressed := make(map[string]int)
+	released := make(map[string]int)
+
+	for _, name := range names {
+		name := name
+		m.Register(&Action{
+			Name:    name,
+			Press:   func() { pressed[name]++ },
+			Release: func() { released[name]++ },
+		})
+	}
+
+	return m, pressed, released
+}
+
+func mustParse(t *testing.T, str string) Binding {
+	b, err := ParseBinding(str)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestActionMapPressRelease(t *testing.T) {
+	m, pressed, released := testActions("pan", "step")
+
+	err := m.Load(map[string][]string{
+		"pan":  {"Space", "MouseMiddle"},
+		"step": {"E"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	space := mustParse(t, "Space")
+	middle := mustParse(t, "MouseMiddle")
+
+	if !m.Press(space) || !m.Press(middle) {
+		t.Fatal("pan was not triggered")
+	}
+
+	// Holding a second input of the same action does not press it again,
+	// and the action is active until both are let go.
+	m.Release(space)
+	if !m.Active("pan") || released["pan"] != 0 {
+		t.Fatal("pan was released while an input is still held")
+	}
+
+	m.Release(middle)
+	if m.Active("pan") || released["pan"] != 1 {
+		t.Fatal("pan was not released")
+	}
+
+	if pressed["pan"] != 2 {
+		t.Fatalf("pan pressed %d times, want 2", pressed["pan"])
+	}
+
+	if m.Press(mustParse(t, "R")) {
+		t.Fatal("unbound input triggered an action")
+	}
+
+	if !m.Trigger(mustParse(t, "E")) || pressed["step"] != 1 || m.Active("step") {
+		t.Fatal("step was not triggered once")
+	}
+}
+
+func TestActionMapModifiers(t *testing.T) {
+	m, pressed, _ := testActions("pan", "zoom-in", "step", "step-back", "rewind", "save")
+
+	err := m.Load(map[string][]string{
+		"pan":       {"Space"},
+		"zoom-in":   {"ScrollUp"},
+		"step":      {"E"},
+		"step-back": {"Shift+E"},
+		"rewind":    {"Ctrl+E"},
+		"save":      {"Ctrl+Shift+S"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Shift+Space", "pan"},
+		{"Ctrl+ScrollUp", "zoom-in"},
+		{"E", "step"},
+		{"Shift+E", "step-back"},
+		{"Alt+Shift+E", "step-back"},
+		{"Ctrl+Shift+E", ""}, // Both step-back and rewind match.
+		{"Shift+S", ""},
+		{"Ctrl+Shift+S", "save"},
+	}
+
+	for _, tt := range tests {
+		for name := range pressed {
+			delete(pressed, name)
+		}
+
+		ok := m.Trigger(mustParse(t, tt.in))
+		if ok != (tt.want != "") {
+			t.Errorf("%s: triggered = %v", tt.in, ok)
+			continue
+		}
+
+		if tt.want != "" && (len(pressed) != 1 || pressed[tt.want] != 1) {
+			t.Errorf("%s: pressed %v, want %s", tt.in, pressed, tt.want)
+		}
+	}
+
+	// A release after the modifier was let go still reaches the action.
+	m.Press(mustParse(t, "Shift+Space"))
+	m.Release(mustParse(t, "Space"))
+	if m.Active("pan") {
+		t.Error("pan is still active")
+	}
+}
+
+func TestActionMapLoadErrors(t *testing.T) {
+	m, _, _ := testActions("step", "rewind")
+
+	tests := []map[string][]string{
+		{"step": {"E"}, "rewind": {"E"}},
+		{"step": {"Ctrl+E"}, "rewind": {"control+e"}},
+		{"missing": {"E"}},
+		{"step": {"Nope"}},
+	}
+
+	for _, bindings := range tests {
+		if err := m.Load(bindings); err == nil {
+			t.Errorf("%v: expected an error", bindings)
+		}
+	}
+
+	// Binding the same input twice to one action is fine.
+	if err := m.Load(map[string][]string{"step": {"E", "e"}}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// keyNames maps key names, as used in configuration files, to their keys.
+// Names follow the glfw constants, without the Key prefix. They refer to
+// physical key positions on a US keyboard layout.
+var keyNames = map[string]glfw.Key{
+	"Space":        glfw.KeySpace,
+	"Apostrophe":   glfw.KeyApostrophe,
+	"Comma":        glfw.KeyComma,
+	"Minus":        glfw.KeyMinus,
+	"Period":       glfw.KeyPeriod,
+	"Slash":        glfw.KeySlash,
+	"0":            glfw.Key0,
+	"1":            glfw.Key1,
+	"2":            glfw.Key2,
+	"3":            glfw.Key3,
+	"4":            glfw.Key4,
+	"5":            glfw.Key5,
+	"6":            glfw.Key6,
+	"7":            glfw.Key7,
+	"8":            glfw.Key8,
+	"9":            glfw.Key9,
+	"Semicolon":    glfw.KeySemicolon,
+	"Equal":        glfw.KeyEqual,
+	"A":            glfw.KeyA,
+	"B":            glfw.KeyB,
+	"C":            glfw.KeyC,
+	"D":            glfw.KeyD,
+	"E":            glfw.KeyE,
+	"F":            glfw.KeyF,
+	"G":            glfw.KeyG,
+	"H":            glfw.KeyH,
+	"I":            glfw.KeyI,
+	"J":            glfw.KeyJ,
+	"K":            glfw.KeyK,
+	"L":            glfw.KeyL,
+	"M":            glfw.KeyM,
+	"N":            glfw.KeyN,
+	"O":            glfw.KeyO,
+	"P":            glfw.KeyP,
+	"Q":            glfw.KeyQ,
+	"R":            glfw.KeyR,
+	"S":            glfw.KeyS,
+	"T":            glfw.KeyT,
+	"U":            glfw.KeyU,
+	"V":            glfw.KeyV,
+	"W":            glfw.KeyW,
+	"X":            glfw.KeyX,
+	"Y":            glfw.KeyY,
+	"Z":            glfw.KeyZ,
+	"LeftBracket":  glfw.KeyLeftBracket,
+	"Backslash":    glfw.KeyBackslash,
+	"RightBracket": glfw.KeyRightBracket,
+	"GraveAccent":  glfw.KeyGraveAccent,
+	"World1":       glfw.KeyWorld1,
+	"World2":       glfw.KeyWorld2,
+	"Escape":       glfw.KeyEscape,
+	"Enter":        glfw.KeyEnter,
+	"Tab":          glfw.KeyTab,
+	"Backspace":    glfw.KeyBackspace,
+	"Insert":       glfw.KeyInsert,
+	"Delete":       glfw.KeyDelete,
+	"Right":        glfw.KeyRight,
+	"Left":         glfw.KeyLeft,
+	"Down":         glfw.KeyDown,
+	"Up":           glfw.KeyUp,
+	"PageUp":       glfw.KeyPageUp,
+	"PageDown":     glfw.KeyPageDown,
+	"Home":         glfw.KeyHome,
+	"End":          glfw.KeyEnd,
+	"CapsLock":     glfw.KeyCapsLock,
+	"ScrollLock":   glfw.KeyScrollLock,
+	"NumLock":      glfw.KeyNumLock,
+	"PrintScreen":  glfw.KeyPrintScreen,
+	"Pause":        glfw.KeyPause,
+	"F1":           glfw.KeyF1,
+	"F2":           glfw.KeyF2,
+	"F3":           glfw.KeyF3,
+	"F4":           glfw.KeyF4,
+	"F5":           glfw.KeyF5,
+	"F6":           glfw.KeyF6,
+	"F7":           glfw.KeyF7,
+	"F8":           glfw.KeyF8,
+	"F9":           glfw.KeyF9,
+	"F10":          glfw.KeyF10,
+	"F11":          glfw.KeyF11,
+	"F12":          glfw.KeyF12,
+	"F13":          glfw.KeyF13,
+	"F14":          glfw.KeyF14,
+	"F15":          glfw.KeyF15,
+	"F16":          glfw.KeyF16,
+	"F17":          glfw.KeyF17,
+	"F18":          glfw.KeyF18,
+	"F19":          glfw.KeyF19,
+	"F20":          glfw.KeyF20,
+	"F21":          glfw.KeyF21,
+	"F22":          glfw.KeyF22,
+	"F23":          glfw.KeyF23,
+	"F24":          glfw.KeyF24,
+	"F25":          glfw.KeyF25,
+	"KP0":          glfw.KeyKP0,
+	"KP1":          glfw.KeyKP1,
+	"KP2":          glfw.KeyKP2,
+	"KP3":          glfw.KeyKP3,
+	"KP4":          glfw.KeyKP4,
+	"KP5":          glfw.KeyKP5,
+	"KP6":          glfw.KeyKP6,
+	"KP7":          glfw.KeyKP7,
+	"KP8":          glfw.KeyKP8,
+	"KP9":          glfw.KeyKP9,
+	"KPDecimal":    glfw.KeyKPDecimal,
+	"KPDivide":     glfw.KeyKPDivide,
+	"KPMultiply":   glfw.KeyKPMultiply,
+	"KPSubtract":   glfw.KeyKPSubtract,
+	"KPAdd":        glfw.KeyKPAdd,
+	"KPEnter":      glfw.KeyKPEnter,
+	"KPEqual":      glfw.KeyKPEqual,
+	"LeftShift":    glfw.KeyLeftShift,
+	"LeftControl":  glfw.KeyLeftControl,
+	"LeftAlt":      glfw.KeyLeftAlt,
+	"LeftSuper":    glfw.KeyLeftSuper,
+	"RightShift":   glfw.KeyRightShift,
+	"RightControl": glfw.KeyRightControl,
+	"RightAlt":     glfw.KeyRightAlt,
+	"RightSuper":   glfw.KeyRightSuper,
+	"Menu":         glfw.KeyMenu,
+}
+
+// parseKey returns the key with the given name. Names are case insensitive.
+func parseKey(name string) (glfw.Key, error) {
+	for n, k := range keyNames {
+		if strings.EqualFold(n, name) {
+			return k, nil
+		}
+	}
+	return glfw.KeyUnknown, fmt.Errorf("unknown key %q", name)
+}
+
+// keyName returns the name of the given key.
+// Returns an empty string for unknown keys.
+func keyName(key glfw.Key) string {
+	for n, k := range keyNames {
+		if k == key {
+			return n
+		}
+	}
+	return ""
+}
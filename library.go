@@ -0,0 +1,188 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/hexaflex/wireworld-gpu/math"
+)
+
+// Layout of the component library panel, in screen pixels.
+const (
+	libraryPadding   = 6  // Space around each thumbnail.
+	libraryThumbSize = 96 // Maximum width or height of a thumbnail.
+	libraryMaxScale  = 6  // Maximum screen pixels per cell in a thumbnail.
+)
+
+// libraryBackground is the background color of the library panel.
+var libraryBackground = color.RGBA{0x20, 0x20, 0x20, 0xd0}
+
+// libraryPosition is the screen position of the library panel.
+var libraryPosition = image.Pt(8, 8)
+
+// Library shows the components from the component library in a panel, from
+// which they can be picked for placement.
+type Library struct {
+	components []*Component
+	pal        *Palette
+	panel      *Panel
+	slots      []image.Rectangle
+	selected   int
+	visible    bool
+}
+
+// NewLibrary creates a hidden library panel for the given components.
+func NewLibrary(components []*Component, shader Shader, pal *Palette) *Library {
+	l := &Library{
+		components: components,
+		pal:        pal,
+		panel:      NewPanel(shader),
+	}
+	l.update()
+	return l
+}
+
+// Release cleans up resources.
+func (l *Library) Release() {
+	l.panel.Release()
+}
+
+// Len returns the number of components.
+func (l *Library) Len() int {
+	return len(l.components)
+}
+
+// Visible returns true if the panel is shown.
+func (l *Library) Visible() bool {
+	return l.visible && len(l.components) > 0
+}
+
+// SetVisible shows or hides the panel.
+func (l *Library) SetVisible(visible bool) {
+	l.visible = visible
+}
+
+// Selected returns the selected component.
+// Returns nil if the library is empty.
+func (l *Library) Selected() *Component {
+	if len(l.components) == 0 {
+		return nil
+	}
+	return l.components[l.selected]
+}
+
+// Select selects the component with the given index. The index wraps
+// around at both ends of the list.
+func (l *Library) Select(i int) {
+	if n := len(l.components); n > 0 {
+		l.selected = ((i % n) + n) % n
+		l.update()
+	}
+}
+
+// SelectNext selects the component which is the given number of places
+// down the list from the selected one.
+func (l *Library) SelectNext(n int) {
+	l.Select(l.selected + n)
+}
+
+// ComponentAt selects and returns the component shown at the given screen
+// position. Returns nil if the panel is hidden, or there is no component.
+func (l *Library) ComponentAt(pos math.Vec2) *Component {
+	if !l.Visible() {
+		return nil
+	}
+
+	p := image.Pt(int(pos[0]), int(pos[1])).Sub(libraryPosition)
+	for i, r := range l.slots {
+		if p.In(r) {
+			l.Select(i)
+			return l.components[i]
+		}
+	}
+
+	return nil
+}
+
+// Draw renders the panel, if it is visible.
+func (l *Library) Draw() {
+	if l.Visible() {
+		l.panel.Draw()
+	}
+}
+
+// update redraws the panel contents.
+func (l *Library) update() {
+	if len(l.components) == 0 {
+		return
+	}
+
+	img, slots := libraryImage(l.components, l.selected, l.pal)
+	l.slots = slots
+	l.panel.SetImage(img)
+	l.panel.SetPosition(libraryPosition)
+}
+
+// libraryImage draws the thumbnails of all components below each other,
+// with the selected one outlined. It returns the image and the area of
+// each thumbnail in it, including padding.
+func libraryImage(lib []*Component, selected int, pal *Palette) (*image.RGBA, []image.Rectangle) {
+	slots := make([]image.Rectangle, len(lib))
+	scales := make([]int, len(lib))
+
+	var size image.Point
+	for i, c := range lib {
+		scales[i] = libraryScale(c.Cells)
+		thumb := c.Cells.Bounds().Size().Mul(scales[i])
+
+		slots[i] = image.Rect(0, size.Y, thumb.X+2*libraryPadding, size.Y+thumb.Y+2*libraryPadding)
+		size.Y = slots[i].Max.Y
+		if slots[i].Max.X > size.X {
+			size.X = slots[i].Max.X
+		}
+	}
+
+	img := image.NewRGBA(image.Rectangle{Max: size})
+	draw.Draw(img, img.Bounds(), image.NewUniform(libraryBackground), image.Point{}, draw.Src)
+
+	for i, c := range lib {
+		slots[i].Max.X = size.X
+		if i == selected {
+			drawOutline(img, slots[i], withAlpha(SelectionColor, 0xff), 2)
+		}
+
+		s := scales[i]
+		min := slots[i].Min.Add(image.Pt(libraryPadding, libraryPadding))
+		for y := 0; y < c.Cells.Height; y++ {
+			for x := 0; x < c.Cells.Width; x++ {
+				r := image.Rect(x*s, y*s, (x+1)*s, (y+1)*s).Add(min)
+				draw.Draw(img, r, image.NewUniform(pal.CellColor(c.Cells.At(x, y))), image.Point{}, draw.Src)
+			}
+		}
+	}
+
+	return img, slots
+}
+
+// libraryScale returns the number of screen pixels per cell for the
+// thumbnail of the given cells.
+func libraryScale(g *Grid) int {
+	s := libraryThumbSize / max(max(g.Width, g.Height), 1)
+	if s < 1 {
+		return 1
+	}
+	if s > libraryMaxScale {
+		return libraryMaxScale
+	}
+	return s
+}
+
+// drawOutline draws the outline of r into img, with the given line width.
+func drawOutline(img draw.Image, r image.Rectangle, c color.Color, width int) {
+	u := image.NewUniform(c)
+	draw.Draw(img, image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+width), u, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(r.Min.X, r.Max.Y-width, r.Max.X, r.Max.Y), u, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(r.Min.X, r.Min.Y, r.Min.X+width, r.Max.Y), u, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(r.Max.X-width, r.Min.Y, r.Max.X, r.Max.Y), u, image.Point{}, draw.Src)
+}
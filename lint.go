@@ -0,0 +1,337 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"sort"
+	"strings"
+)
+
+// LintColor is the overlay color of cells flagged by lint checks.
+var LintColor = color.RGBA{0xff, 0xc0, 0x00, 0xe0}
+
+// Names of the lint checks.
+const (
+	LintHeadWithoutTail = "head-without-tail" // A head without an adjacent tail has no clear direction.
+	LintTailWithoutHead = "tail-without-head" // A tail without an adjacent head is left over from an electron.
+	LintIsolated        = "isolated"          // Heads and tails without any wire go nowhere.
+	LintBorder          = "border"            // Wire on the edge connects to the opposite edge.
+	LintCloseHeads      = "close-heads"       // Heads on opposite sides of a tail; the trailing one dies.
+)
+
+// LintChecks lists the names of all lint checks.
+var LintChecks = []string{
+	LintHeadWithoutTail,
+	LintTailWithoutHead,
+	LintIsolated,
+	LintBorder,
+	LintCloseHeads,
+}
+
+// LintFinding is a suspicious pattern found by a lint check.
+type LintFinding struct {
+	Check   string        // Name of the check.
+	Pos     image.Point   // Cell the finding refers to.
+	Message string        // Description of the problem.
+	Cells   []image.Point // Cells involved, for highlighting.
+}
+
+func (f LintFinding) String() string {
+	return fmt.Sprintf("%d:%d: %s: %s", f.Pos.X, f.Pos.Y, f.Check, f.Message)
+}
+
+// Lint checks g for patterns which are most likely mistakes, like those
+// made when drawing a circuit in an image editor. Checks named in ignore
+// are skipped. The findings are ordered by position, row by row.
+func (g *Grid) Lint(ignore ...string) []LintFinding {
+	var out []LintFinding
+	w, h := g.Width, g.Height
+
+	enabled := func(check string) bool {
+		for _, c := range ignore {
+			if c == check {
+				return false
+			}
+		}
+		return true
+	}
+
+	// neighbours returns the indices of the cells around i, wrapping
+	// around the edges like the simulation does.
+	neighbours := func(i int) [8]int {
+		var n [8]int
+		x, y := i%w, i/w
+		for j, d := range neighbours8 {
+			n[j] = ((y+d.Y+h)%h)*w + (x+d.X+w)%w
+		}
+		return n
+	}
+
+	point := func(i int) image.Point {
+		return image.Pt(i%w, i/w)
+	}
+
+	add := func(check string, i int, msg string, cells ...int) {
+		f := LintFinding{Check: check, Pos: point(i), Message: msg}
+		for _, c := range append([]int{i}, cells...) {
+			f.Cells = append(f.Cells, point(c))
+		}
+		out = append(out, f)
+	}
+
+	// Count the wire cells of each net, to find electrons which are not
+	// part of any wire.
+	nets := g.Nets()
+	wires := make([]int, len(nets.Nets)+1)
+	for i, cell := range g.Pix {
+		if cell == CellWire {
+			wires[nets.Labels[i]]++
+		}
+	}
+
+	for i, cell := range g.Pix {
+		if cell != CellHead && cell != CellTail {
+			continue
+		}
+
+		var heads, tails int
+		for _, n := range neighbours(i) {
+			switch g.Pix[n] {
+			case CellHead:
+				heads++
+			case CellTail:
+				tails++
+			}
+		}
+
+		switch {
+		case wires[nets.Labels[i]] == 0 && enabled(LintIsolated):
+			add(LintIsolated, i, "electron is not part of any wire")
+		case cell == CellHead && tails == 0 && enabled(LintHeadWithoutTail):
+			add(LintHeadWithoutTail, i, "head without an adjacent tail; its direction is ambiguous")
+		case cell == CellTail && heads == 0 && enabled(LintTailWithoutHead):
+			add(LintTailWithoutHead, i, "tail without an adjacent head")
+		}
+	}
+
+	if enabled(LintCloseHeads) {
+		out = append(out, g.lintCloseHeads()...)
+	}
+
+	if enabled(LintBorder) {
+		for _, n := range nets.Nets {
+			var cells []image.Point
+			for y := n.Bounds.Min.Y; y < n.Bounds.Max.Y; y++ {
+				for x := n.Bounds.Min.X; x < n.Bounds.Max.X; x++ {
+					onEdge := x == 0 || y == 0 || x == w-1 || y == h-1
+					if onEdge && nets.At(x, y) == n.ID {
+						cells = append(cells, image.Pt(x, y))
+					}
+				}
+			}
+
+			if len(cells) > 0 {
+				out = append(out, LintFinding{
+					Check:   LintBorder,
+					Pos:     cells[0],
+					Message: fmt.Sprintf("net %d touches the border at %d cells; signals wrap around to the opposite edge", n.ID, len(cells)),
+					Cells:   cells,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i].Pos, out[j].Pos
+		return a.Y < b.Y || (a.Y == b.Y && a.X < b.X)
+	})
+	return out
+}
+
+// lintCloseHeads finds heads on opposite sides of a tail. On a wire one
+// cell wide, the tail of the leading electron keeps the trailing one from
+// moving on, so it dies. A signal which fans out at a junction looks much
+// the same, so a pair is only reported if stepping the cells around it
+// once shows that one of the heads goes nowhere.
+func (g *Grid) lintCloseHeads() []LintFinding {
+	// The cells next to the heads are at most two cells from the tail.
+	// Their next state depends on the cells one further out.
+	const radius = 3
+
+	var out []LintFinding
+	w, h := g.Width, g.Height
+
+	// wrap returns p moved into the grid, like the simulation wraps
+	// around the edges.
+	wrap := func(p image.Point) image.Point {
+		return image.Pt((p.X%w+w)%w, (p.Y%h+h)%h)
+	}
+
+	at := func(p image.Point) byte {
+		p = wrap(p)
+		return g.Pix[p.Y*w+p.X]
+	}
+
+	// dies returns true if none of the cells around p in local became
+	// a head.
+	dies := func(local *Grid, p image.Point) bool {
+		for _, d := range neighbours8 {
+			if n := p.Add(d); local.At(n.X, n.Y) == CellHead {
+				return false
+			}
+		}
+		return true
+	}
+
+	for i, cell := range g.Pix {
+		if cell != CellTail {
+			continue
+		}
+
+		tail := image.Pt(i%w, i/w)
+		center := image.Pt(radius, radius)
+		var local *Grid
+
+		for _, d := range neighbours8 {
+			// Each pair is found from both sides. Only look at one.
+			if d.Y < 0 || (d.Y == 0 && d.X < 0) {
+				continue
+			}
+
+			if at(tail.Add(d)) != CellHead || at(tail.Sub(d)) != CellHead {
+				continue
+			}
+
+			if local == nil {
+				local = NewGrid(2*radius+1, 2*radius+1)
+				for y := 0; y < local.Height; y++ {
+					for x := 0; x < local.Width; x++ {
+						local.Set(x, y, at(tail.Add(image.Pt(x, y)).Sub(center)))
+					}
+				}
+				local.Step(1)
+			}
+
+			var dead, other image.Point
+			switch {
+			case dies(local, center.Add(d)):
+				dead, other = tail.Add(d), tail.Sub(d)
+			case dies(local, center.Sub(d)):
+				dead, other = tail.Sub(d), tail.Add(d)
+			default:
+				continue
+			}
+
+			dead, other = wrap(dead), wrap(other)
+			out = append(out, LintFinding{
+				Check:   LintCloseHeads,
+				Pos:     dead,
+				Message: fmt.Sprintf("head is too close behind the one at %d:%d; it dies", other.X, other.Y),
+				Cells:   []image.Point{dead, tail, other},
+			})
+		}
+	}
+
+	return out
+}
+
+// lintImage draws the findings over the cells of g. The circuit is dimmed
+// and the flagged cells are highlighted.
+func lintImage(g *Grid, findings []LintFinding, pal *Palette) *image.RGBA {
+	img := pal.dimmedImage(g)
+	for _, f := range findings {
+		markCells(img, f.Cells, LintColor)
+	}
+	return img
+}
+
+var lintCommand = &Command{
+	Name:  "lint",
+	Usage: "<file>",
+	Brief: "Checks a circuit for malformed electrons and other likely mistakes.",
+	Run:   runLint,
+}
+
+func runLint(cmd *Command, args []string) error {
+	var pal Palette
+	pal.LoadDefault()
+
+	fs := newFlagSet(cmd)
+	paletteFlags(fs, &pal)
+	output := fs.String("o", StdStream, "Output file for the findings, or - for stdout.")
+	imageFile := fs.String("image", "", "Also write the circuit with the flagged cells highlighted to this PNG file.")
+	ignore := fs.String("ignore", "", "Comma separated list of checks to skip: "+strings.Join(LintChecks, ", ")+".")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("missing input file")
+	}
+
+	var skip []string
+	if len(*ignore) > 0 {
+		skip = strings.Split(*ignore, ",")
+		for _, s := range skip {
+			if !isLintCheck(s) {
+				return fmt.Errorf("unknown check %q", s)
+			}
+		}
+	}
+
+	g, err := LoadGrid(fs.Arg(0), &pal)
+	if err != nil {
+		return err
+	}
+
+	findings := g.Lint(skip...)
+
+	w, err := createOutput(*output)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range findings {
+		fmt.Fprintf(w, "%s:%s\n", fs.Arg(0), f)
+	}
+
+	if err = w.Close(); err != nil {
+		return err
+	}
+
+	if len(*imageFile) > 0 {
+		fd, err := createOutput(*imageFile)
+		if err != nil {
+			return err
+		}
+
+		if err = png.Encode(fd, lintImage(g, findings, &pal)); err != nil {
+			fd.Close()
+			return err
+		}
+
+		if err = fd.Close(); err != nil {
+			return err
+		}
+	}
+
+	if len(findings) > 0 {
+		return fmt.Errorf("found %d problems", len(findings))
+	}
+	return nil
+}
+
+// isLintCheck returns true if name is the name of a lint check.
+func isLintCheck(name string) bool {
+	for _, c := range LintChecks {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,31 @@
+package main
+
+import "log"
+
+// toggleLint runs the lint checks on the current state and marks the
+// flagged cells in the overlay, or clears the marks if they are shown.
+func (a *Application) toggleLint() {
+	if a.lint != nil {
+		a.lint = nil
+		a.updatePreview()
+		return
+	}
+
+	findings := a.simulation.Cells(a.simulation.Bounds()).Lint()
+	for _, f := range findings {
+		log.Println(f)
+	}
+	log.Printf("found %d problems", len(findings))
+
+	if len(findings) > 0 {
+		a.lint = findings
+	}
+	a.updatePreview()
+}
+
+// drawLint marks the cells flagged by toggleLint in the overlay.
+func (a *Application) drawLint() {
+	for _, f := range a.lint {
+		a.overlay.SetPoints(f.Cells, LintColor)
+	}
+}
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"os"
 	"runtime"
 
 	"github.com/go-gl/glfw/v3.3/glfw"
@@ -12,6 +15,13 @@ func init() {
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		if cmd := findCommand(os.Args[1]); cmd != nil {
+			runCommand(cmd, os.Args[2:])
+			return
+		}
+	}
+
 	var app Application
 
 	app.Initialize()
@@ -23,3 +33,16 @@ func main() {
 		glfw.PollEvents()
 	}
 }
+
+// runCommand runs the given command line tool and exits the program with
+// an error if it fails.
+func runCommand(cmd *Command, args []string) {
+	err := cmd.Run(cmd, args)
+	switch {
+	case err == flag.ErrHelp:
+		os.Exit(0)
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "%s: %v\n", cmd.Name, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"path/filepath"
+	"strings"
+)
+
+// Manifest describes a circuit assembled from other circuit files and
+// components from the component library.
+//
+// It is stored as JSON:
+//
+//	{
+//	  "width": 200,
+//	  "height": 100,
+//	  "parts": [
+//	    {"file": "alu.png", "x": 10, "y": 20},
+//	    {"file": "clock.rle", "x": 120, "y": 20, "rotation": 90, "mirror": true},
+//	    {"component": "diode", "x": 120, "y": 60}
+//	  ]
+//	}
+//
+// The size is optional. It defaults to the extent of the placed parts.
+type Manifest struct {
+	Width   int            `json:"width"`   // Width of the circuit, or 0 to fit the parts.
+	Height  int            `json:"height"`  // Height of the circuit, or 0 to fit the parts.
+	Library string         `json:"library"` // Component library directory, relative to the manifest. Defaults to ComponentDir.
+	Parts   []ManifestPart `json:"parts"`   // Parts to place, in drawing order.
+}
+
+// ManifestPart defines a circuit file or library component placed by a
+// manifest. Exactly one of File and Component is set.
+type ManifestPart struct {
+	File      string `json:"file,omitempty"`      // Circuit file, relative to the manifest. May be another manifest.
+	Component string `json:"component,omitempty"` // Name of a component in the library.
+	X         int    `json:"x"`                   // Position of the top-left corner, after transformation.
+	Y         int    `json:"y"`                   // Position of the top-left corner, after transformation.
+	Rotation  int    `json:"rotation"`            // Clockwise rotation in degrees.
+	Mirror    bool   `json:"mirror"`              // Mirror horizontally, before rotating?
+}
+
+// Pos returns the position of the part's top-left corner.
+func (p *ManifestPart) Pos() image.Point {
+	return image.Pt(p.X, p.Y)
+}
+
+// Assembly is a circuit built from a manifest.
+type Assembly struct {
+	Cells       *Grid
+	Annotations *Annotations // Merged annotations of the parts, or nil.
+	Components  []Placed     // Library components placed by the manifest itself.
+}
+
+// Placed is a library component placed in an assembly. It has been
+// transformed, so its pins are relative to Pos.
+type Placed struct {
+	*Component
+	Pos image.Point
+}
+
+// isManifest returns true if data looks like a JSON manifest.
+func isManifest(data []byte) bool {
+	data = bytes.TrimLeft(data, " \t\r\n")
+	return len(data) > 0 && data[0] == '{'
+}
+
+// readManifest decodes a manifest and checks it for errors.
+func readManifest(data []byte) (*Manifest, error) {
+	var m Manifest
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&m); err != nil {
+		return nil, err
+	}
+
+	if m.Width < 0 || m.Height < 0 {
+		return nil, errors.New("width and height must be >= 0")
+	}
+
+	if len(m.Parts) == 0 {
+		return nil, errors.New("no parts")
+	}
+
+	for i, p := range m.Parts {
+		switch {
+		case len(p.File) == 0 && len(p.Component) == 0:
+			return nil, fmt.Errorf("part %d: missing file or component", i)
+		case len(p.File) > 0 && len(p.Component) > 0:
+			return nil, fmt.Errorf("part %d: has both a file and a component", i)
+		case p.X < 0 || p.Y < 0:
+			return nil, fmt.Errorf("part %d: position must be >= 0", i)
+		case p.Rotation%90 != 0:
+			return nil, fmt.Errorf("part %d: rotation %d is not a multiple of 90", i, p.Rotation)
+		}
+	}
+
+	return &m, nil
+}
+
+// loadManifest builds the circuit described by the manifest in data, which
+// was read from the given file. Part files and the component library are
+// relative to the directory of the manifest. Annotations of the parts are
+// merged, up to the maximum number of annotation colors.
+func loadManifest(file string, data []byte, pal *Palette, parents []string) (*Assembly, error) {
+	m, err := readManifest(data)
+	if err != nil {
+		return nil, fmt.Errorf("manifest %s: %v", file, err)
+	}
+
+	dir := "."
+	if file != StdStream {
+		if file, err = filepath.Abs(file); err != nil {
+			return nil, err
+		}
+		dir = filepath.Dir(file)
+	}
+
+	for _, p := range parents {
+		if p == file {
+			return nil, fmt.Errorf("manifest %s includes itself: %s", file, strings.Join(append(parents, file), " -> "))
+		}
+	}
+	parents = append(parents, file)
+
+	var out Assembly
+	var lib []*Component
+	var extent image.Rectangle
+	cells := make([]*Grid, len(m.Parts))
+	anns := make([]*Grid, len(m.Parts))
+	colors := make([]color.Palette, len(m.Parts))
+
+	for i, p := range m.Parts {
+		// Parts are placed as components, so files and library
+		// components are transformed the same way.
+		var c *Component
+
+		if len(p.Component) > 0 {
+			if lib == nil {
+				if lib, err = LoadLibrary(manifestPath(dir, m.Library, ComponentDir), pal); err != nil {
+					return nil, fmt.Errorf("manifest %s: %v", file, err)
+				}
+			}
+
+			if c = findComponent(lib, p.Component); c == nil {
+				return nil, fmt.Errorf("manifest %s: part %d: unknown component %q", file, i, p.Component)
+			}
+		} else {
+			pix, size, ann, err := loadNestedCells(manifestPath(dir, p.File, ""), pal, parents)
+			if err != nil {
+				return nil, fmt.Errorf("manifest %s: part %d: %v", file, i, err)
+			}
+
+			w, h := int(size[0]), int(size[1])
+			c = &Component{Name: p.File, Cells: &Grid{pix, w, h}}
+
+			if ann != nil {
+				a, err := (&Component{Cells: &Grid{ann.Pix, w, h}}).Transform(p.Rotation, p.Mirror)
+				if err != nil {
+					return nil, fmt.Errorf("manifest %s: part %d: %v", file, i, err)
+				}
+				anns[i] = a.Cells
+				colors[i] = ann.Colors
+			}
+		}
+
+		if c, err = c.Transform(p.Rotation, p.Mirror); err != nil {
+			return nil, fmt.Errorf("manifest %s: part %d: %v", file, i, err)
+		}
+
+		if len(p.Component) > 0 {
+			out.Components = append(out.Components, Placed{c, p.Pos()})
+		}
+
+		cells[i] = c.Cells
+		extent = extent.Union(cells[i].Bounds().Add(p.Pos()))
+	}
+
+	width, height := m.Width, m.Height
+	if width == 0 {
+		width = extent.Max.X
+	}
+	if height == 0 {
+		height = extent.Max.Y
+	}
+
+	out.Cells = NewGrid(width, height)
+	for i, p := range m.Parts {
+		out.Cells.Blit(p.Pos(), cells[i])
+	}
+
+	out.Annotations = mergeAnnotations(m.Parts, anns, colors, width, height)
+	return &out, nil
+}
+
+// manifestPath returns the given path, relative to the manifest directory
+// dir. An empty path is replaced by def.
+func manifestPath(dir, path, def string) string {
+	if len(path) == 0 {
+		path = def
+	}
+
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// mergeAnnotations combines the annotations of the placed parts. Each
+// entry in anns holds the color indices of a part, after transformation,
+// or nil if it has none. Returns nil if no part has annotations.
+func mergeAnnotations(parts []ManifestPart, anns []*Grid, colors []color.Palette, width, height int) *Annotations {
+	var out *Grid
+	var merged color.Palette
+	index := make(map[color.Color]byte)
+
+	for i, a := range anns {
+		if a == nil {
+			continue
+		}
+
+		if out == nil {
+			out = NewGrid(width, height)
+		}
+
+		// Translate the part's color indices to the merged palette.
+		remap := make([]byte, len(colors[i])+1)
+		for j, c := range colors[i] {
+			n, ok := index[c]
+			if !ok {
+				if len(merged) >= MaxAnnotationColors {
+					continue
+				}
+				merged = append(merged, c)
+				n = byte(len(merged))
+				index[c] = n
+			}
+			remap[j+1] = n
+		}
+
+		part := a.Clone()
+		for j, n := range part.Pix {
+			part.Pix[j] = remap[n]
+		}
+
+		out.Blit(parts[i].Pos(), part)
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return &Annotations{
+		Colors: merged,
+		Pix:    out.Pix,
+		Size:   out.Size(),
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"image"
+	"sort"
+)
+
+// Net is a connected group of conductor cells. A signal on any of its
+// cells can travel to all others.
+type Net struct {
+	ID        int             // Label of the net's cells. Nets are numbered from 1.
+	Cells     int             // Number of conductor cells.
+	Bounds    image.Rectangle // Bounding box of the cells.
+	Endpoints []image.Point   // Cells with at most one conductor neighbour.
+}
+
+// NetMap labels the cells of a circuit with the net they belong to.
+type NetMap struct {
+	Labels []uint32 // Net ID of each cell, row by row, or 0 for empty cells.
+	Nets   []Net    // Nets, ordered by ID.
+	Width  int
+	Height int
+}
+
+// At returns the ID of the net at the given cell. Returns 0 if there is
+// none.
+func (m *NetMap) At(x, y int) int {
+	if x < 0 || y < 0 || x >= m.Width || y >= m.Height {
+		return 0
+	}
+	return int(m.Labels[y*m.Width+x])
+}
+
+// Net returns the net with the given ID. Returns nil if there is none.
+func (m *NetMap) Net(id int) *Net {
+	if id < 1 || id > len(m.Nets) {
+		return nil
+	}
+	return &m.Nets[id-1]
+}
+
+// Nets labels the 8-connected groups of conductor cells. Wire, head and
+// tail cells all conduct. Connections wrap around the grid edges, like
+// the simulation rules do. Nets are numbered in the order of their first
+// cell, row by row.
+func (g *Grid) Nets() *NetMap {
+	m := NetMap{
+		Labels: make([]uint32, len(g.Pix)),
+		Width:  g.Width,
+		Height: g.Height,
+	}
+
+	conductor := func(i int) bool {
+		return g.Pix[i] != CellEmpty
+	}
+
+	w, h := g.Width, g.Height
+	for _, seg := range g.segments(conductor) {
+		n := Net{ID: len(m.Nets) + 1, Cells: len(seg)}
+
+		for _, i := range seg {
+			m.Labels[i] = uint32(n.ID)
+
+			p := image.Pt(i%w, i/w)
+			n.Bounds = n.Bounds.Union(image.Rectangle{p, p.Add(image.Pt(1, 1))})
+
+			links := 0
+			for _, d := range neighbours8 {
+				if conductor(((p.Y+d.Y+h)%h)*w + (p.X+d.X+w)%w) {
+					links++
+				}
+			}
+
+			if links <= 1 {
+				n.Endpoints = append(n.Endpoints, p)
+			}
+		}
+
+		// Cells are visited depth first. Sort endpoints row by row, so
+		// the output does not depend on the order of the search.
+		sortPoints(n.Endpoints)
+		m.Nets = append(m.Nets, n)
+	}
+
+	return &m
+}
+
+// sortPoints sorts the given points row by row.
+func sortPoints(points []image.Point) {
+	sort.Slice(points, func(i, j int) bool {
+		a, b := points[i], points[j]
+		return a.Y < b.Y || (a.Y == b.Y && a.X < b.X)
+	})
+}
+
+// netsJSON is the JSON form of a NetMap, as written by the nets command.
+type netsJSON struct {
+	Width  int       `json:"width"`
+	Height int       `json:"height"`
+	Nets   []netJSON `json:"nets"`
+}
+
+// netJSON is the JSON form of a Net.
+type netJSON struct {
+	ID        int      `json:"id"`
+	Cells     int      `json:"cells"`
+	X         int      `json:"x"`      // Position of the bounding box.
+	Y         int      `json:"y"`      // Position of the bounding box.
+	Width     int      `json:"width"`  // Width of the bounding box.
+	Height    int      `json:"height"` // Height of the bounding box.
+	Endpoints [][2]int `json:"endpoints"`
+}
+
+var netsCommand = &Command{
+	Name:  "nets",
+	Usage: "<file>",
+	Brief: "Lists the connected wire nets of a circuit as JSON.",
+	Run:   runNets,
+}
+
+func runNets(cmd *Command, args []string) error {
+	var pal Palette
+	pal.LoadDefault()
+
+	fs := newFlagSet(cmd)
+	paletteFlags(fs, &pal)
+	output := fs.String("o", StdStream, "Output file, or - for stdout.")
+	min := fs.Int("min", 1, "Leave out nets with fewer cells.")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("missing input file")
+	}
+
+	if *min < 1 {
+		return errors.New("min must be > 0")
+	}
+
+	g, err := LoadGrid(fs.Arg(0), &pal)
+	if err != nil {
+		return err
+	}
+
+	m := g.Nets()
+	out := netsJSON{Width: m.Width, Height: m.Height, Nets: []netJSON{}}
+
+	for _, n := range m.Nets {
+		if n.Cells < *min {
+			continue
+		}
+
+		nj := netJSON{
+			ID:        n.ID,
+			Cells:     n.Cells,
+			X:         n.Bounds.Min.X,
+			Y:         n.Bounds.Min.Y,
+			Width:     n.Bounds.Dx(),
+			Height:    n.Bounds.Dy(),
+			Endpoints: make([][2]int, len(n.Endpoints)),
+		}
+
+		for i, p := range n.Endpoints {
+			nj.Endpoints[i] = [2]int{p.X, p.Y}
+		}
+
+		out.Nets = append(out.Nets, nj)
+	}
+
+	w, err := createOutput(*output)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err = enc.Encode(out); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
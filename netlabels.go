@@ -0,0 +1,39 @@
+package main
+
+import (
+	"github.com/go-gl/gl/v4.2-core/gl"
+)
+
+// NetLabels is a texture which holds the net ID of each cell, for the
+// display shader to highlight a net with.
+type NetLabels struct {
+	tex uint32
+}
+
+// NewNetLabels creates a texture with the labels in m.
+func NewNetLabels(m *NetMap) *NetLabels {
+	var l NetLabels
+	gl.GenTextures(1, &l.tex)
+	gl.BindTexture(gl.TEXTURE_2D, l.tex)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 4)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.R32UI, int32(m.Width), int32(m.Height), 0, gl.RED_INTEGER, gl.UNSIGNED_INT, gl.Ptr(m.Labels))
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+	return &l
+}
+
+// Release cleans up resources.
+func (l *NetLabels) Release() {
+	gl.DeleteTextures(1, &l.tex)
+}
+
+// Bind sets the label texture as the active texture.
+func (l *NetLabels) Bind() {
+	gl.BindTexture(gl.TEXTURE_2D, l.tex)
+}
+
+// Unbind unbinds the label texture.
+func (l *NetLabels) Unbind() {
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+}
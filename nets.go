@@ -0,0 +1,67 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"log"
+)
+
+// Colors of the highlighted net.
+var (
+	NetColor         = color.RGBA{0x40, 0xff, 0xff, 0xa0} // Blended over the net's cells.
+	NetEndpointColor = color.RGBA{0xff, 0xff, 0x40, 0xe0} // Marks the net's endpoints.
+)
+
+// netMap returns the nets of the current simulation. They are found when
+// first needed after the conductors changed. The simulation rules never
+// change which cells conduct, so only edits and replacements do.
+func (a *Application) netMap() *NetMap {
+	if a.nets == nil {
+		a.nets = a.simulation.Cells(a.simulation.Bounds()).Nets()
+		a.netLabels = NewNetLabels(a.nets)
+	}
+	return a.nets
+}
+
+// highlightNet highlights the net at the given cell. Clicking the same
+// net again, or an empty cell, removes the highlight.
+func (a *Application) highlightNet(cell image.Point) {
+	id := a.netMap().At(cell.X, cell.Y)
+	if id == a.net {
+		id = 0
+	}
+
+	a.net = id
+	a.display.SetHighlight(id, NetColor)
+	a.updatePreview()
+
+	if n := a.nets.Net(id); n != nil {
+		log.Printf("net %d: %d cells, %d endpoints, bounds %v", n.ID, n.Cells, len(n.Endpoints), n.Bounds)
+	}
+}
+
+// netsChanged discards the nets and the highlight, after the conductors
+// may have changed.
+func (a *Application) netsChanged() {
+	if a.netLabels != nil {
+		a.netLabels.Release()
+		a.netLabels = nil
+	}
+
+	a.nets = nil
+	if a.net != 0 {
+		a.net = 0
+		a.display.SetHighlight(0, NetColor)
+	}
+}
+
+// drawNet marks the endpoints of the highlighted net in the overlay.
+func (a *Application) drawNet() {
+	if a.nets == nil {
+		return
+	}
+
+	if n := a.nets.Net(a.net); n != nil {
+		a.overlay.SetPoints(n.Endpoints, NetEndpointColor)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/go-gl/gl/v4.2-core/gl"
+	"github.com/hexaflex/wireworld-gpu/math"
+)
+
+// Overlay is a texture which the display shader draws over the simulation.
+// It holds a color for each cell. The alpha channel determines how much of
+// the overlay color is blended into the cell color.
+//
+// Changes are made in client memory and uploaded the next time the overlay
+// is bound. Only the region which changed is uploaded.
+type Overlay struct {
+	img   *image.RGBA
+	drawn image.Rectangle // Bounds of all non-transparent pixels.
+	dirty image.Rectangle // Region which needs uploading.
+	tex   uint32
+}
+
+// NewOverlay creates a new, transparent overlay with the given dimensions.
+func NewOverlay(size math.Vec2) *Overlay {
+	var o Overlay
+	gl.GenTextures(1, &o.tex)
+	gl.BindTexture(gl.TEXTURE_2D, o.tex)
+	gl.TexParameterf(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	gl.TexParameterf(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+	o.SetSize(size)
+	return &o
+}
+
+// Release cleans up resources.
+func (o *Overlay) Release() {
+	gl.DeleteTextures(1, &o.tex)
+}
+
+// SetSize resizes the overlay. This clears its contents.
+func (o *Overlay) SetSize(size math.Vec2) {
+	o.img = image.NewRGBA(image.Rect(0, 0, int(size[0]), int(size[1])))
+	o.drawn = image.Rectangle{}
+	o.dirty = image.Rectangle{}
+
+	gl.BindTexture(gl.TEXTURE_2D, o.tex)
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA8, int32(size[0]), int32(size[1]), 0, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(o.img.Pix))
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+}
+
+// Bounds returns the overlay dimensions.
+func (o *Overlay) Bounds() image.Rectangle {
+	return o.img.Rect
+}
+
+// Clear makes the overlay fully transparent.
+func (o *Overlay) Clear() {
+	if o.drawn.Empty() {
+		return
+	}
+
+	for y := o.drawn.Min.Y; y < o.drawn.Max.Y; y++ {
+		row := o.img.Pix[o.img.PixOffset(o.drawn.Min.X, y):o.img.PixOffset(o.drawn.Max.X, y)]
+		for i := range row {
+			row[i] = 0
+		}
+	}
+
+	o.dirty = o.dirty.Union(o.drawn)
+	o.drawn = image.Rectangle{}
+}
+
+// Set sets the color of the given cell. Cells outside the overlay are ignored.
+func (o *Overlay) Set(x, y int, c color.RGBA) {
+	p := image.Pt(x, y)
+	if !p.In(o.img.Rect) {
+		return
+	}
+
+	o.img.SetRGBA(x, y, c)
+
+	r := image.Rectangle{p, p.Add(image.Pt(1, 1))}
+	o.drawn = o.drawn.Union(r)
+	o.dirty = o.dirty.Union(r)
+}
+
+// SetPoints sets the color of all the given cells.
+func (o *Overlay) SetPoints(points []image.Point, c color.RGBA) {
+	for _, p := range points {
+		o.Set(p.X, p.Y, c)
+	}
+}
+
+// SetRect sets the color of all cells in the given rectangle.
+func (o *Overlay) SetRect(r image.Rectangle, c color.RGBA) {
+	r = r.Intersect(o.img.Rect)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			o.img.SetRGBA(x, y, c)
+		}
+	}
+
+	o.drawn = o.drawn.Union(r)
+	o.dirty = o.dirty.Union(r)
+}
+
+// Bind uploads any pending changes and sets the overlay texture as the
+// active texture.
+func (o *Overlay) Bind() {
+	gl.BindTexture(gl.TEXTURE_2D, o.tex)
+
+	if !o.dirty.Empty() {
+		r := o.dirty
+		gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+		gl.PixelStorei(gl.UNPACK_ROW_LENGTH, int32(o.img.Rect.Dx()))
+		gl.TexSubImage2D(gl.TEXTURE_2D, 0, int32(r.Min.X), int32(r.Min.Y), int32(r.Dx()), int32(r.Dy()),
+			gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(o.img.Pix[o.img.PixOffset(r.Min.X, r.Min.Y):]))
+		gl.PixelStorei(gl.UNPACK_ROW_LENGTH, 0)
+		o.dirty = image.Rectangle{}
+	}
+}
+
+// Unbind unbinds the overlay texture.
+func (o *Overlay) Unbind() {
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+}
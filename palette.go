@@ -33,23 +33,86 @@ func (p *Palette) LoadDefault() {
 	p.Tail = color.RGBA{0x99, 0xff, 0x00, 0xff}
 }
 
-// fromInternalFormat converts the given 8bpp pixel buffer into an RGBA image
-// with colors from the pallette.
-func (p *Palette) fromInternalFormat(pix []byte, size math.Vec2) image.Image {
+// Color indices of the cell states in indexed images.
+// These match the order of the colors returned by Palette.Colors.
+const (
+	IndexEmpty = iota
+	IndexWire
+	IndexHead
+	IndexTail
+)
+
+// CellIndex returns the color index of the given cell state. Indexed images
+// and the display shader color cells through this mapping, so it needs to
+// stay in sync with cellIndex in the `shared` shader source in
+// shader_shared.go
+func CellIndex(cell byte) uint8 {
+	switch cell {
+	case CellWire:
+		return IndexWire
+	case CellHead:
+		return IndexHead
+	case CellTail:
+		return IndexTail
+	default:
+		return IndexEmpty
+	}
+}
+
+// Colors returns the palette colors, ordered by their Index constants.
+func (p *Palette) Colors() color.Palette {
+	return color.Palette{p.Empty, p.Wire, p.Head, p.Tail}
+}
+
+// CellColor returns the color of the given cell state.
+func (p *Palette) CellColor(cell byte) color.RGBA {
+	return [...]color.RGBA{p.Empty, p.Wire, p.Head, p.Tail}[CellIndex(cell)]
+}
+
+// fromInternalFormat converts the given 8bpp pixel buffer into an indexed
+// image with colors from the pallette. If ann is not nil, its colors are
+// dimmedImage draws the cells of g at reduced brightness, so cells marked
+// over them stand out. Reports of the lint, deadwires and heatmap commands
+// are drawn this way.
+func (p *Palette) dimmedImage(g *Grid) *image.RGBA {
+	img := image.NewRGBA(g.Bounds())
+
+	for i, cell := range g.Pix {
+		c := p.CellColor(cell)
+		img.SetRGBA(i%g.Width, i/g.Width, color.RGBA{c.R * 2 / 5, c.G * 2 / 5, c.B * 2 / 5, 0xff})
+	}
+
+	return img
+}
+
+// markCells draws the given cells of img in c, fully opaque.
+func markCells(img *image.RGBA, cells []image.Point, c color.RGBA) {
+	c.A = 0xff
+	for _, p := range cells {
+		img.SetRGBA(p.X, p.Y, c)
+	}
+}
+
+// appended to the image palette and drawn over empty cells.
+func (p *Palette) fromInternalFormat(pix []byte, size math.Vec2, ann *Annotations) *image.Paletted {
 	w, h := int(size[0]), int(size[1])
-	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	colors := p.Colors()
+	if ann != nil {
+		colors = append(colors, ann.Colors...)
+	}
+
+	img := image.NewPaletted(image.Rect(0, 0, w, h), colors)
 
 	for y := 0; y < h; y++ {
 		for x := 0; x < w; x++ {
-			switch pix[y*w+x] {
-			case CellWire:
-				img.Set(x, y, p.Wire)
-			case CellHead:
-				img.Set(x, y, p.Head)
-			case CellTail:
-				img.Set(x, y, p.Tail)
-			default:
-				img.Set(x, y, p.Empty)
+			i := y*w + x
+
+			img.Pix[i] = CellIndex(pix[i])
+
+			if img.Pix[i] == IndexEmpty && ann != nil {
+				if c := ann.At(x, y); c > 0 {
+					img.Pix[i] = IndexTail + c
+				}
 			}
 		}
 	}
@@ -73,6 +136,51 @@ func (p *Palette) toInternalFormat(img image.Image) ([]byte, math.Vec2) {
 	return out.Pix, math.Vec2{float32(b.Dx()), float32(b.Dy())}
 }
 
+// annotations returns all pixels in the given image which do not match any
+// of the palette colors. Returns nil if there are none.
+func (p *Palette) annotations(img image.Image) *Annotations {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	ann := Annotations{
+		Pix:  make([]byte, w*h),
+		Size: math.Vec2{float32(w), float32(h)},
+	}
+
+	known := p.Colors()
+	index := make(map[color.RGBA]byte)
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			cr, cg, cb, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			c := color.RGBA{byte(cr >> 8), byte(cg >> 8), byte(cb >> 8), 0xff}
+			if paletteContains(known, c) {
+				continue
+			}
+
+			n, ok := index[c]
+			if !ok {
+				// Indexed images can not hold more than 256 colors. Any
+				// annotation colors beyond that are dropped.
+				if len(ann.Colors) >= MaxAnnotationColors {
+					continue
+				}
+
+				ann.Colors = append(ann.Colors, c)
+				n = byte(len(ann.Colors))
+				index[c] = n
+			}
+
+			ann.Pix[y*w+x] = n
+		}
+	}
+
+	if len(ann.Colors) == 0 {
+		return nil
+	}
+
+	return &ann
+}
+
 // toCellState translates color c to its internal simulation representation.
 func (p *Palette) toCellState(c color.Color) color.Color {
 	switch {
@@ -87,6 +195,16 @@ func (p *Palette) toCellState(c color.Color) color.Color {
 	}
 }
 
+// paletteContains returns true if c matches any color in pal.
+func paletteContains(pal color.Palette, c color.Color) bool {
+	for _, pc := range pal {
+		if colorEquals(pc, c) {
+			return true
+		}
+	}
+	return false
+}
+
 // colorEquals returns true if the two colors have the same component values.
 func colorEquals(a, b color.Color) bool {
 	ar, ag, ab, _ := a.RGBA()
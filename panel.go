@@ -0,0 +1,109 @@
+package main
+
+import (
+	"image"
+
+	"github.com/go-gl/gl/v4.2-core/gl"
+	"github.com/hexaflex/wireworld-gpu/math"
+)
+
+// Panel draws an image in screen space, on top of the simulation display.
+// One image pixel covers one screen pixel.
+type Panel struct {
+	shader Shader
+	pos    image.Point
+	size   image.Point
+	tex    uint32
+	vao    uint32
+	vbo    uint32
+}
+
+// NewPanel creates a new, empty panel.
+func NewPanel(shader Shader) *Panel {
+	var p Panel
+	var verts = []float32{
+		// x,y,u,v
+		0, 0, 0, 0,
+		1, 0, 1, 0,
+		0, 1, 0, 1,
+		1, 0, 1, 0,
+		1, 1, 1, 1,
+		0, 1, 0, 1}
+
+	p.shader = shader
+
+	gl.GenVertexArrays(1, &p.vao)
+	gl.BindVertexArray(p.vao)
+
+	gl.GenBuffers(1, &p.vbo)
+	gl.BindBuffer(gl.ARRAY_BUFFER, p.vbo)
+	gl.EnableVertexAttribArray(0)
+	gl.EnableVertexAttribArray(1)
+	gl.VertexAttribPointer(0, 2, gl.FLOAT, false, 4*4, gl.PtrOffset(0))
+	gl.VertexAttribPointer(1, 2, gl.FLOAT, false, 4*4, gl.PtrOffset(2*4))
+	gl.BufferData(gl.ARRAY_BUFFER, len(verts)*4, gl.Ptr(verts), gl.STATIC_DRAW)
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
+	gl.BindVertexArray(0)
+
+	gl.GenTextures(1, &p.tex)
+	gl.BindTexture(gl.TEXTURE_2D, p.tex)
+	gl.TexParameterf(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	gl.TexParameterf(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+	return &p
+}
+
+// Release cleans up resources.
+func (p *Panel) Release() {
+	gl.DeleteTextures(1, &p.tex)
+	gl.DeleteBuffers(1, &p.vbo)
+	gl.DeleteVertexArrays(1, &p.vao)
+}
+
+// SetImage sets the image shown by the panel.
+func (p *Panel) SetImage(img *image.RGBA) {
+	p.size = img.Rect.Size()
+
+	gl.BindTexture(gl.TEXTURE_2D, p.tex)
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+	gl.PixelStorei(gl.UNPACK_ROW_LENGTH, int32(img.Stride/4))
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA8, int32(p.size.X), int32(p.size.Y), 0, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(img.Pix))
+	gl.PixelStorei(gl.UNPACK_ROW_LENGTH, 0)
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+}
+
+// SetPosition sets the screen position of the panel's top-left corner.
+func (p *Panel) SetPosition(pos image.Point) {
+	p.pos = pos
+}
+
+// Bounds returns the screen area covered by the panel.
+func (p *Panel) Bounds() image.Rectangle {
+	return image.Rectangle{p.pos, p.pos.Add(p.size)}
+}
+
+// Draw renders the panel.
+func (p *Panel) Draw() {
+	r := p.Bounds()
+	if r.Empty() {
+		return
+	}
+
+	m := math.Translate3D(float32(r.Min.X), float32(r.Min.Y), 0)
+	m = m.Mul4(math.Scale3D(float32(r.Dx()), float32(r.Dy()), 1))
+
+	p.shader.Use()
+	p.shader.SetUniformMat4("Model", m)
+
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindTexture(gl.TEXTURE_2D, p.tex)
+	gl.BindVertexArray(p.vao)
+	gl.DrawArrays(gl.TRIANGLES, 0, 6)
+	gl.BindVertexArray(0)
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+
+	p.shader.Unuse()
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"log"
+	"os"
+)
+
+// RegionColor is the overlay color of counted regions.
+var RegionColor = color.RGBA{0x30, 0xd0, 0xd0, 0x90}
+
+// regionFile returns the name of the region file for the input.
+// Returns an empty string if there is none, as for input read from stdin.
+func (a *Application) regionFile() string {
+	return a.sidecarFile(a.config.Regions, RegionExt)
+}
+
+// loadRegions loads the counted regions for the input, if it has any.
+func (a *Application) loadRegions() {
+	a.loadSidecar("regions", a.config.Regions, RegionExt, func(file string) (int, error) {
+		regions, err := LoadRegions(file)
+		if err == nil {
+			a.regions = regions
+		}
+		return len(regions), err
+	})
+}
+
+// setPopulation enables or disables population counting. Enabling it
+// starts a new series.
+func (a *Application) setPopulation(enabled bool) {
+	if a.population != nil {
+		a.population.Release()
+		a.population = nil
+	}
+
+	if enabled {
+		p, err := NewPopulationSampler(a.regions, a.config.PopulationHistory)
+		if err != nil {
+			log.Println("failed to enable population counts:", err)
+		} else {
+			a.population = p
+		}
+	}
+
+	a.updateSamplers()
+	a.updatePreview()
+}
+
+// togglePopulation enables or disables population counting.
+func (a *Application) togglePopulation() {
+	a.setPopulation(a.population == nil)
+
+	if a.population != nil {
+		log.Println("population counts enabled")
+	} else {
+		log.Println("population counts disabled")
+	}
+}
+
+// setRegions replaces the counted regions. This starts a new series.
+func (a *Application) setRegions(regions []Region) {
+	a.regions = regions
+	if a.population != nil {
+		a.setPopulation(true)
+	}
+}
+
+// populationState describes the most recent population counts, for the
+// title bar. Returns an empty string if there are none.
+func (a *Application) populationState() string {
+	if a.population == nil {
+		return ""
+	}
+
+	s := a.population.Series()
+	counts := s.At(s.End() - 1)
+	if counts == nil {
+		return ""
+	}
+
+	p := counts[0]
+	return fmt.Sprintf("wire: %d, heads: %d, tails: %d", p.Wire, p.Head, p.Tail)
+}
+
+// exportPopulation writes the recorded population counts to a CSV file.
+// This waits for counts which are still in transit from the GPU.
+func (a *Application) exportPopulation() {
+	if a.population == nil {
+		log.Println("population counts are disabled")
+		return
+	}
+
+	a.population.Sync()
+
+	s := a.population.Series()
+	if s.Len() == 0 {
+		log.Println("no population counts recorded yet")
+		return
+	}
+
+	series := *s
+	series.Counts = append([]Population(nil), s.Counts...)
+
+	file := a.outputFile("csv")
+	go writeCSVFile(file, &series)
+}
+
+// writeCSVFile writes the given series to a CSV file.
+func writeCSVFile(file string, s *PopulationSeries) {
+	log.Printf("saving generations %d-%d to %s", s.Start, s.End()-1, file)
+
+	fd, err := os.Create(file)
+	if err != nil {
+		log.Println("failed to create CSV file:", err)
+		return
+	}
+
+	if err = s.WriteCSV(fd); err != nil {
+		log.Println("failed to write CSV file:", err)
+		fd.Close()
+		return
+	}
+
+	if err = fd.Close(); err != nil {
+		log.Println("failed to save CSV file:", err)
+	}
+}
+
+// drawRegions outlines the counted regions in the overlay, while their
+// population is counted.
+func (a *Application) drawRegions() {
+	if a.population == nil {
+		return
+	}
+
+	for _, r := range a.regions {
+		a.overlay.SetPoints(rectPoints(r.Rect.Min, r.Rect.Max.Sub(image.Pt(1, 1)), false), RegionColor)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import "image"
+
+// PopulationSampler counts the cells in each state every generation, in the
+// whole simulation and in a set of regions, without stalling the GPU. The
+// counts are appended to the series when the data arrives.
+type PopulationSampler struct {
+	*RegionCounter
+	series *PopulationSeries
+}
+
+// NewPopulationSampler creates a sampler for the given regions. The series
+// keeps at most limit generations, or all of them if limit is 0.
+func NewPopulationSampler(regions []Region, limit int) (*PopulationSampler, error) {
+	var err error
+	var p PopulationSampler
+
+	p.series = NewPopulationSeries(regions, limit)
+
+	rects := []image.Rectangle{image.Rect(0, 0, allCells, allCells)}
+	for _, r := range regions {
+		rects = append(rects, r.Rect)
+	}
+
+	p.RegionCounter, err = NewRegionCounter(rects, p.process)
+	if err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+// Series returns the counts received so far.
+func (p *PopulationSampler) Series() *PopulationSeries {
+	return p.series
+}
+
+// process appends the gathered counts to the series.
+func (p *PopulationSampler) process(first uint64, data []byte) {
+	cells := readCellCounts(data)
+
+	counts := make([]Population, len(cells))
+	for i, c := range cells {
+		counts[i] = Population{
+			Wire: c[IndexWire],
+			Head: c[IndexHead],
+			Tail: c[IndexTail],
+		}
+	}
+
+	p.series.Append(first, counts)
+}
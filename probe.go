@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// ProbeExt is appended to the name of a circuit file to find its probes.
+const ProbeExt = ".probes"
+
+// Probe marks a cell whose state is recorded every generation.
+type Probe struct {
+	Name string // Signal name. Must not contain white space.
+	X, Y int    // Cell position.
+}
+
+// LoadProbes reads probes from the given file.
+func LoadProbes(file string) ([]Probe, error) {
+	var probes probeList
+	if err := loadSidecar(file, "probe", &probes); err != nil {
+		return nil, err
+	}
+	return probes, nil
+}
+
+// SaveProbes writes the given probes to a file.
+func SaveProbes(file string, probes []Probe) error {
+	lines := make([]string, len(probes))
+	for i, p := range probes {
+		lines[i] = fmt.Sprintf("%s %d %d", p.Name, p.X, p.Y)
+	}
+	return saveSidecar(file, lines)
+}
+
+// loadProbesFor reads the probes of the given circuit file from the named
+// probe file. If that is empty, they are read from the circuit's own probe
+// file, if there is one.
+func loadProbesFor(input, file string) ([]Probe, error) {
+	var probes probeList
+	if err := loadSidecarFor(input, file, ProbeExt, "probe", &probes); err != nil {
+		return nil, err
+	}
+	return probes, nil
+}
+
+// probeList collects the probes of a probe file.
+type probeList []Probe
+
+// add parses a line of a probe file, which holds the name of a probe and
+// the position of its cell.
+func (l *probeList) add(fields []string) error {
+	if len(fields) != 3 {
+		return errors.New("expected <name> <x> <y>")
+	}
+
+	var p Probe
+	var err error
+	p.Name = fields[0]
+
+	if p.X, err = strconv.Atoi(fields[1]); err != nil {
+		return fmt.Errorf("invalid x; %v", err)
+	}
+
+	if p.Y, err = strconv.Atoi(fields[2]); err != nil {
+		return fmt.Errorf("invalid y; %v", err)
+	}
+
+	if findProbeName(*l, p.Name) >= 0 {
+		return fmt.Errorf("duplicate name %q", p.Name)
+	}
+
+	*l = append(*l, p)
+	return nil
+}
+
+// findProbe returns the index of the probe at the given cell.
+// Returns -1 if there is none.
+func findProbe(probes []Probe, x, y int) int {
+	for i, p := range probes {
+		if p.X == x && p.Y == y {
+			return i
+		}
+	}
+	return -1
+}
+
+// findProbeName returns the index of the probe with the given name.
+// Returns -1 if there is none.
+func findProbeName(probes []Probe, name string) int {
+	for i, p := range probes {
+		if p.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// newProbeName returns an unused probe name of the form p<n>.
+func newProbeName(probes []Probe) string {
+	for n := len(probes); ; n++ {
+		name := "p" + strconv.Itoa(n)
+		if findProbeName(probes, name) < 0 {
+			return name
+		}
+	}
+}
+
+var probeCommand = &Command{
+	Name:  "probe",
+	Usage: "<file>",
+	Brief: "Simulates a circuit and writes the states of its probes as a VCD waveform.",
+	Run:   runProbe,
+}
+
+func runProbe(cmd *Command, args []string) error {
+	var pal Palette
+	pal.LoadDefault()
+
+	fs := newFlagSet(cmd)
+	paletteFlags(fs, &pal)
+	output := fs.String("o", StdStream, "Output file, or - for stdout.")
+	probeFile := fs.String("probes", "", "Probe file. Defaults to the input file name with "+ProbeExt+" appended.")
+	steps := fs.Int("steps", 1000, "Number of generations to simulate.")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("missing input file")
+	}
+
+	if *steps < 0 {
+		return errors.New("steps must be >= 0")
+	}
+
+	if len(*probeFile) == 0 {
+		*probeFile = sidecarFile(fs.Arg(0), ProbeExt)
+		if len(*probeFile) == 0 {
+			return errors.New("probes must be given when reading from stdin")
+		}
+	}
+
+	probes, err := LoadProbes(*probeFile)
+	if err != nil {
+		return err
+	}
+
+	if len(probes) == 0 {
+		return fmt.Errorf("%s defines no probes", *probeFile)
+	}
+
+	g, err := LoadGrid(fs.Arg(0), &pal)
+	if err != nil {
+		return err
+	}
+
+	trace := NewTrace(probes, 0)
+	sample := make([]byte, len(probes))
+
+	for gen := 0; ; gen++ {
+		for i, p := range probes {
+			sample[i] = g.At(p.X, p.Y)
+		}
+		trace.Append(uint64(gen), sample)
+
+		if gen == *steps {
+			break
+		}
+		g.Step(1)
+	}
+
+	w, err := createOutput(*output)
+	if err != nil {
+		return err
+	}
+
+	if err := writeVCD(w, trace, circuitName(fs.Arg(0))); err != nil {
+		w.Close()
+		return err
+	}
+
+	return w.Close()
+}
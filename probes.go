@@ -0,0 +1,173 @@
+package main
+
+import (
+	"image"
+	"log"
+	"os"
+)
+
+// probeFile returns the name of the probe file for the input.
+// Returns an empty string if there is none, as for input read from stdin.
+func (a *Application) probeFile() string {
+	return a.sidecarFile(a.config.Probes, ProbeExt)
+}
+
+// loadProbes loads the probes for the input, if it has any.
+func (a *Application) loadProbes() {
+	a.loadSidecar("probes", a.config.Probes, ProbeExt, func(file string) (int, error) {
+		probes, err := LoadProbes(file)
+		if err == nil {
+			a.setProbes(probes)
+		}
+		return len(probes), err
+	})
+}
+
+// setProbes replaces the probes which are sampled every generation.
+// This starts a new recording.
+func (a *Application) setProbes(probes []Probe) {
+	if a.probes != nil {
+		a.probes.Release()
+		a.probes = nil
+	}
+
+	if len(probes) > 0 {
+		p, err := NewProbeSampler(probes, a.config.ProbeHistory)
+		if err != nil {
+			log.Println("failed to create probes:", err)
+		} else {
+			a.probes = p
+		}
+	}
+
+	a.updateSamplers()
+	a.updatePreview()
+}
+
+// currentProbes returns a copy of the probes being sampled.
+func (a *Application) currentProbes() []Probe {
+	if a.probes == nil {
+		return nil
+	}
+	return append([]Probe(nil), a.probes.Probes()...)
+}
+
+// toggleProbe adds a probe to the given cell, or removes the one which is
+// already there.
+func (a *Application) toggleProbe(cell image.Point) {
+	probes := a.currentProbes()
+
+	if i := findProbe(probes, cell.X, cell.Y); i >= 0 {
+		log.Printf("removed probe %s at %d,%d", probes[i].Name, cell.X, cell.Y)
+		probes = append(probes[:i], probes[i+1:]...)
+	} else {
+		p := Probe{newProbeName(probes), cell.X, cell.Y}
+		log.Printf("added probe %s at %d,%d", p.Name, p.X, p.Y)
+		probes = append(probes, p)
+	}
+
+	a.setProbes(probes)
+}
+
+// saveProbes writes the probes to the probe file of the input.
+func (a *Application) saveProbes() {
+	file := a.probeFile()
+	if len(file) == 0 {
+		log.Println("input from stdin has no probe file; use -probes to name one")
+		return
+	}
+
+	if err := SaveProbes(file, a.currentProbes()); err != nil {
+		log.Println("failed to save probes:", err)
+		return
+	}
+
+	log.Println("saved probes to", file)
+}
+
+// exportVCD writes the recorded probe states to a VCD file. This waits
+// for samples which are still in transit from the GPU.
+func (a *Application) exportVCD() {
+	if a.probes == nil {
+		log.Println("no probes to export")
+		return
+	}
+
+	a.probes.Sync()
+
+	t := a.probes.Trace()
+	if t.Len() == 0 {
+		log.Println("no probe samples recorded yet")
+		return
+	}
+
+	trace := *t
+	trace.Samples = append([]byte(nil), t.Samples...)
+
+	file := a.outputFile("vcd")
+	go writeVCDFile(file, &trace, circuitName(a.config.Input))
+}
+
+// writeVCDFile writes the given trace to a VCD file.
+func writeVCDFile(file string, t *Trace, module string) {
+	log.Printf("saving generations %d-%d to %s", t.Start, t.End()-1, file)
+
+	fd, err := os.Create(file)
+	if err != nil {
+		log.Println("failed to create VCD file:", err)
+		return
+	}
+
+	if err = writeVCD(fd, t, module); err != nil {
+		log.Println("failed to write VCD file:", err)
+		fd.Close()
+		return
+	}
+
+	if err = fd.Close(); err != nil {
+		log.Println("failed to save VCD file:", err)
+	}
+}
+
+// toggleWaveform shows or hides the waveform panel.
+func (a *Application) toggleWaveform() {
+	if a.probes == nil && !a.waveform.Visible() {
+		log.Println("there are no probes to show")
+		return
+	}
+
+	a.waveform.SetVisible(!a.waveform.Visible())
+	a.updatePreview()
+}
+
+// drawWaveform draws the waveform panel, if there are probes.
+func (a *Application) drawWaveform() {
+	if a.probes == nil {
+		return
+	}
+
+	w, h := a.window.GetFramebufferSize()
+	a.waveform.Draw(a.probes.Trace(), a.simulation.Generation(), image.Pt(w, h), &a.config.Palette)
+}
+
+// displaySize returns the size of the viewport of the simulation display.
+// This is the window, minus the waveform panel while it is shown.
+func (a *Application) displaySize() image.Point {
+	w, h := a.window.GetFramebufferSize()
+	if a.probes == nil || !a.waveform.Visible() {
+		return image.Pt(w, h)
+	}
+
+	return image.Pt(w, h-a.waveform.Height(len(a.probes.Probes()), image.Pt(w, h)))
+}
+
+// waveformLane returns the waveform lane under the mouse cursor.
+// Returns -1 if there is none.
+func (a *Application) waveformLane() int {
+	if a.probes == nil {
+		return -1
+	}
+
+	w, h := a.window.GetFramebufferSize()
+	return a.waveform.LaneAt(a.mouse, len(a.probes.Probes()), image.Pt(w, h))
+}
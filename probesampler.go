@@ -0,0 +1,44 @@
+package main
+
+// ProbeSampler records the states of probed cells every generation, without
+// stalling the GPU.
+//
+// A shader pass copies the probed cells into a row of a small texture after
+// each simulation step. The rows are read back in batches and appended to
+// the trace when the data arrives.
+type ProbeSampler struct {
+	*Gatherer
+	trace *Trace
+}
+
+// NewProbeSampler creates a sampler for the given probes, which must not be
+// empty. The trace keeps at most limit generations, or all of them if limit
+// is 0.
+func NewProbeSampler(probes []Probe, limit int) (*ProbeSampler, error) {
+	var err error
+	var p ProbeSampler
+
+	p.trace = NewTrace(probes, limit)
+
+	verts := make([]float32, 0, len(probes)*2)
+	for _, pr := range probes {
+		verts = append(verts, float32(pr.X), float32(pr.Y))
+	}
+
+	p.Gatherer, err = NewGatherer(ProbeShader, verts, []int{2}, GatherByte, p.trace.Append)
+	if err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+// Probes returns the sampled probes.
+func (p *ProbeSampler) Probes() []Probe {
+	return p.trace.Probes
+}
+
+// Trace returns the samples received so far.
+func (p *ProbeSampler) Trace() *Trace {
+	return p.trace
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"github.com/go-gl/gl/v4.2-core/gl"
+	"github.com/hexaflex/wireworld-gpu/math"
+)
+
+// Readback is an asynchronous transfer of pixel data from a framebuffer
+// into client memory.
+//
+// glReadPixels normally blocks until the GPU has finished all pending work.
+// A readback instead has the GPU copy the pixels into a pixel buffer object
+// and places a fence behind that copy. The data can then be fetched once the
+// fence has been signaled, usually a frame or two later, without stalling
+// the pipeline.
+type Readback struct {
+	size  math.Vec2
+	data  []byte
+	fence uintptr
+	pbo   uint32
+}
+
+// newReadback starts reading the given region from the first color attachment
+// of framebuffer fbo. Each pixel is expected to occupy bpp bytes when read with
+// the given format and type.
+func newReadback(fbo uint32, x, y, w, h int32, format, xtype uint32, bpp int) *Readback {
+	var r Readback
+	r.size = math.Vec2{float32(w), float32(h)}
+	length := int(w) * int(h) * bpp
+
+	gl.GenBuffers(1, &r.pbo)
+	gl.BindBuffer(gl.PIXEL_PACK_BUFFER, r.pbo)
+	gl.BufferData(gl.PIXEL_PACK_BUFFER, length, nil, gl.STREAM_READ)
+
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, fbo)
+	gl.PixelStorei(gl.PACK_ALIGNMENT, 1)
+	gl.ReadPixels(x, y, w, h, format, xtype, gl.PtrOffset(0))
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, 0)
+	gl.BindBuffer(gl.PIXEL_PACK_BUFFER, 0)
+
+	r.data = make([]byte, length)
+	r.fence = gl.FenceSync(gl.SYNC_GPU_COMMANDS_COMPLETE, 0)
+
+	// Make sure the fence is actually submitted, or polling it may
+	// never yield a result.
+	gl.Flush()
+	return &r
+}
+
+// Size returns the dimensions of the region being read.
+func (r *Readback) Size() math.Vec2 {
+	return r.size
+}
+
+// Ready returns true if the transfer has completed.
+// This does not block.
+func (r *Readback) Ready() bool {
+	if r.fence == 0 {
+		return true
+	}
+
+	switch gl.ClientWaitSync(r.fence, 0, 0) {
+	case gl.ALREADY_SIGNALED, gl.CONDITION_SATISFIED:
+		r.finish()
+		return true
+	}
+
+	return false
+}
+
+// Wait blocks until the transfer has completed and returns the pixel data.
+func (r *Readback) Wait() []byte {
+	for r.fence != 0 {
+		switch gl.ClientWaitSync(r.fence, gl.SYNC_FLUSH_COMMANDS_BIT, 1e6) {
+		case gl.ALREADY_SIGNALED, gl.CONDITION_SATISFIED, gl.WAIT_FAILED:
+			r.finish()
+		}
+	}
+	return r.data
+}
+
+// Data returns the pixel data. This is nil until Ready returns true.
+func (r *Readback) Data() []byte {
+	if r.fence != 0 {
+		return nil
+	}
+	return r.data
+}
+
+// Release cleans up resources. This discards any pending transfer.
+func (r *Readback) Release() {
+	if r.fence != 0 {
+		gl.DeleteSync(r.fence)
+		r.fence = 0
+	}
+
+	gl.DeleteBuffers(1, &r.pbo)
+	r.pbo = 0
+}
+
+// finish copies the completed transfer into client memory.
+func (r *Readback) finish() {
+	gl.DeleteSync(r.fence)
+	r.fence = 0
+
+	gl.BindBuffer(gl.PIXEL_PACK_BUFFER, r.pbo)
+	gl.GetBufferSubData(gl.PIXEL_PACK_BUFFER, 0, len(r.data), gl.Ptr(r.data))
+	gl.BindBuffer(gl.PIXEL_PACK_BUFFER, 0)
+	gl.DeleteBuffers(1, &r.pbo)
+	r.pbo = 0
+}
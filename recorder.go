@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/gif"
+	"io"
+	"time"
+
+	"github.com/hexaflex/wireworld-gpu/math"
+)
+
+// Known animation formats for recordings.
+const (
+	FormatGIF  = "gif"
+	FormatAPNG = "apng"
+)
+
+// Recorder collects frames from a running simulation, for use in an
+// animated GIF or APNG file.
+//
+// Frames are captured through asynchronous readbacks. They therefore
+// arrive some time after they were requested, which is why a recorder
+// keeps track of the frames still in flight.
+type Recorder struct {
+	palette   Palette
+	frames    []*image.Paletted
+	region    image.Rectangle
+	finish    func(*Recorder)
+	next      uint64
+	every     uint64
+	scale     int
+	maxFrames int
+	pending   int
+	stopped   bool
+}
+
+// NewRecorder creates a new recorder which captures every Nth generation,
+// starting at the given one. Frames are cropped to region, unless it is
+// empty, and scaled up by the given factor.
+//
+// The finish function is called once the recording has been stopped
+// and all its frames have arrived.
+func NewRecorder(c *Config, generation uint64, region image.Rectangle, scale int, finish func(*Recorder)) *Recorder {
+	return &Recorder{
+		palette:   c.Palette,
+		region:    region,
+		finish:    finish,
+		next:      generation,
+		every:     uint64(c.RecordEvery),
+		scale:     scale,
+		maxFrames: c.RecordFrames,
+	}
+}
+
+// Next returns the generation at which the next frame should be captured.
+func (r *Recorder) Next() uint64 {
+	return r.next
+}
+
+// Len returns the number of frames captured so far.
+func (r *Recorder) Len() int {
+	return len(r.frames) + r.pending
+}
+
+// Full returns true if the recording has reached its frame limit.
+func (r *Recorder) Full() bool {
+	return r.Len() >= r.maxFrames
+}
+
+// Capture registers a frame request for the current generation.
+// The returned function should be called with the frame's state data.
+func (r *Recorder) Capture() func(pix []byte, size math.Vec2) {
+	r.pending++
+	r.next += r.every
+	return r.add
+}
+
+// Stop ends the recording. No more frames are captured after this.
+func (r *Recorder) Stop() {
+	if r.stopped {
+		return
+	}
+
+	r.stopped = true
+	if r.pending == 0 {
+		r.finish(r)
+	}
+}
+
+// Encode writes the recorded frames as an animation in the given format.
+// Each frame is shown for the given delay.
+func (r *Recorder) Encode(w io.Writer, format string, delay time.Duration) error {
+	switch format {
+	case FormatGIF:
+		anim := gif.GIF{Image: r.frames}
+		for range r.frames {
+			anim.Delay = append(anim.Delay, int(delay/(10*time.Millisecond)))
+		}
+		return gif.EncodeAll(w, &anim)
+	case FormatAPNG:
+		return encodeAPNG(w, r.frames, delay)
+	default:
+		return fmt.Errorf("unknown animation format %q", format)
+	}
+}
+
+// add adds a frame from the given state data.
+func (r *Recorder) add(pix []byte, size math.Vec2) {
+	img := r.palette.fromInternalFormat(pix, size, nil)
+	if !r.region.Empty() {
+		img = img.SubImage(r.region).(*image.Paletted)
+	}
+
+	r.frames = append(r.frames, scaleImage(img, r.scale))
+	r.pending--
+
+	if r.stopped && r.pending == 0 {
+		r.finish(r)
+	}
+}
+
+// scaleImage returns a copy of img, scaled up by the given integer factor.
+// The result is positioned at the origin.
+func scaleImage(img *image.Paletted, scale int) *image.Paletted {
+	b := img.Bounds()
+	if scale < 1 {
+		scale = 1
+	}
+
+	out := image.NewPaletted(image.Rect(0, 0, b.Dx()*scale, b.Dy()*scale), img.Palette)
+
+	for y := 0; y < out.Rect.Dy(); y++ {
+		row := out.Pix[y*out.Stride:]
+		for x := 0; x < out.Rect.Dx(); x++ {
+			row[x] = img.ColorIndexAt(b.Min.X+x/scale, b.Min.Y+y/scale)
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/go-gl/gl/v4.2-core/gl"
+)
+
+// ReduceTarget is an offscreen render target with four unsigned integers
+// per texel. It holds the first pass of a reduction over the simulation
+// state: a shader reduces each row of cells to a texel, after which the
+// shader of a Gatherer combines the rows. That shader reads the target
+// from texture unit 1.
+type ReduceTarget struct {
+	width  int
+	height int
+	fbo    uint32
+	tex    uint32
+}
+
+// Release clears the target's resources.
+func (t *ReduceTarget) Release() {
+	gl.DeleteFramebuffers(1, &t.fbo)
+	gl.DeleteTextures(1, &t.tex)
+	t.fbo, t.tex = 0, 0
+	t.width, t.height = 0, 0
+}
+
+// Resize (re)allocates the target with the given dimensions. This does
+// nothing if it already has them.
+func (t *ReduceTarget) Resize(width, height int) error {
+	if width == t.width && height == t.height {
+		return nil
+	}
+
+	t.Release()
+
+	if width < 1 || height < 1 {
+		return errors.New("reduce target: invalid dimensions")
+	}
+
+	gl.GenTextures(1, &t.tex)
+	gl.BindTexture(gl.TEXTURE_2D, t.tex)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA32UI, int32(width), int32(height), 0, gl.RGBA_INTEGER, gl.UNSIGNED_INT, nil)
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+
+	gl.GenFramebuffers(1, &t.fbo)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, t.fbo)
+	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, t.tex, 0)
+	status := gl.CheckFramebufferStatus(gl.FRAMEBUFFER)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+
+	if status != gl.FRAMEBUFFER_COMPLETE {
+		t.Release()
+		return errors.New("reduce target: incomplete framebuffer")
+	}
+
+	t.width, t.height = width, height
+	return nil
+}
+
+// Draw runs the first pass of the reduction. It draws count vertices from
+// the given vertex array as triangles, with the given shader, which reads
+// the simulation state from texture unit 0.
+func (t *ReduceTarget) Draw(shader Shader, vao uint32, count int32, state *SimulationState) {
+	shader.Use()
+	gl.Viewport(0, 0, int32(t.width), int32(t.height))
+	gl.BindVertexArray(vao)
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, t.fbo)
+	state.BindTexture()
+
+	gl.DrawArrays(gl.TRIANGLES, 0, count)
+
+	state.UnbindTexture()
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+	gl.BindVertexArray(0)
+	shader.Unuse()
+}
+
+// BindTexture binds the target's texture to texture unit 1, for the second
+// pass of the reduction.
+func (t *ReduceTarget) BindTexture() {
+	gl.ActiveTexture(gl.TEXTURE1)
+	gl.BindTexture(gl.TEXTURE_2D, t.tex)
+	gl.ActiveTexture(gl.TEXTURE0)
+}
+
+// UnbindTexture unbinds the target's texture from texture unit 1.
+func (t *ReduceTarget) UnbindTexture() {
+	gl.ActiveTexture(gl.TEXTURE1)
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+	gl.ActiveTexture(gl.TEXTURE0)
+}
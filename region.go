@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"strconv"
+)
+
+// RegionExt is appended to the name of a circuit file to find its regions.
+const RegionExt = ".regions"
+
+// Region is a named rectangle of cells, whose population is counted
+// separately.
+type Region struct {
+	Name string          // Region name. Must not contain white space.
+	Rect image.Rectangle // Cells in the region.
+}
+
+// LoadRegions reads regions from the given file.
+func LoadRegions(file string) ([]Region, error) {
+	var regions regionList
+	if err := loadSidecar(file, "region", &regions); err != nil {
+		return nil, err
+	}
+	return regions, nil
+}
+
+// loadRegionsFor reads the regions of the given circuit file from the
+// named region file. If that is empty, they are read from the circuit's
+// own region file, if there is one.
+func loadRegionsFor(input, file string) ([]Region, error) {
+	var regions regionList
+	if err := loadSidecarFor(input, file, RegionExt, "region", &regions); err != nil {
+		return nil, err
+	}
+	return regions, nil
+}
+
+// regionList collects the regions of a region file.
+type regionList []Region
+
+// add parses a line of a region file, which holds the name of a region,
+// followed by the position of its top-left cell, its width and its height.
+func (l *regionList) add(fields []string) error {
+	if len(fields) != 5 {
+		return errors.New("expected <name> <x> <y> <width> <height>")
+	}
+
+	var v [4]int
+	for i, f := range fields[1:] {
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return err
+		}
+		v[i] = n
+	}
+
+	if v[2] < 1 || v[3] < 1 {
+		return errors.New("width and height must be > 0")
+	}
+
+	name := fields[0]
+	if findRegion(*l, name) >= 0 {
+		return fmt.Errorf("duplicate name %q", name)
+	}
+
+	*l = append(*l, Region{name, image.Rect(v[0], v[1], v[0]+v[2], v[1]+v[3])})
+	return nil
+}
+
+// findRegion returns the index of the region with the given name.
+// Returns -1 if there is none.
+func findRegion(regions []Region, name string) int {
+	for i, r := range regions {
+		if r.Name == name {
+			return i
+		}
+	}
+	return -1
+}
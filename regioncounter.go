@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/binary"
+	"image"
+
+	"github.com/go-gl/gl/v4.2-core/gl"
+)
+
+// allCells is a region which covers any simulation. Its coordinates are
+// still exact as floats.
+const allCells = 1 << 24
+
+// CellCounts holds the number of cells in each state, indexed by the
+// palette index of the state.
+type CellCounts [4]int
+
+// RegionCounter counts the cells in each state within a set of regions,
+// every generation, without stalling the GPU.
+//
+// The counts are computed by a reduction in two passes. The first counts
+// the cells of each region in each row, into a ReduceTarget with one
+// column per region. The second adds up the rows of each column into a row
+// of the gather texture, which is read back in batches. No fragment reads
+// more than one row or column, however large the regions are.
+type RegionCounter struct {
+	*Gatherer
+	rows   Shader
+	target ReduceTarget
+	vao    uint32
+	vbo    uint32
+}
+
+// NewRegionCounter creates a counter for the given regions. Function done
+// receives the counts of consecutive generations, starting at first, as
+// read back from the GPU. They are decoded with readCellCounts.
+func NewRegionCounter(rects []image.Rectangle, done func(first uint64, data []byte)) (*RegionCounter, error) {
+	var err error
+	var c RegionCounter
+
+	columns := len(rects)
+
+	var verts []float32
+	var points []float32
+	for i, r := range rects {
+		// A quad which covers column i of the reduce target.
+		x0 := float32(i)/float32(columns)*2 - 1
+		x1 := float32(i+1)/float32(columns)*2 - 1
+		x, y := float32(r.Min.X), float32(r.Min.Y)
+		w, h := float32(r.Dx()), float32(r.Dy())
+
+		for _, v := range [][2]float32{{x0, -1}, {x1, -1}, {x0, 1}, {x1, -1}, {x1, 1}, {x0, 1}} {
+			verts = append(verts, v[0], v[1], x, y, w, h)
+		}
+		points = append(points, float32(i))
+	}
+
+	c.rows, err = RegionRowShader.Compile()
+	if err != nil {
+		return nil, err
+	}
+
+	c.Gatherer, err = NewGatherer(RegionShader, points, []int{1}, GatherUint4, done)
+	if err != nil {
+		c.rows.Release()
+		return nil, err
+	}
+
+	gl.GenVertexArrays(1, &c.vao)
+	gl.BindVertexArray(c.vao)
+
+	gl.GenBuffers(1, &c.vbo)
+	gl.BindBuffer(gl.ARRAY_BUFFER, c.vbo)
+	gl.EnableVertexAttribArray(0)
+	gl.EnableVertexAttribArray(1)
+	gl.VertexAttribPointer(0, 2, gl.FLOAT, false, 6*4, gl.PtrOffset(0))
+	gl.VertexAttribPointer(1, 4, gl.FLOAT, false, 6*4, gl.PtrOffset(2*4))
+	gl.BufferData(gl.ARRAY_BUFFER, len(verts)*4, gl.Ptr(verts), gl.STATIC_DRAW)
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
+	gl.BindVertexArray(0)
+
+	return &c, nil
+}
+
+// Release cleans up resources.
+func (c *RegionCounter) Release() {
+	gl.DeleteBuffers(1, &c.vbo)
+	gl.DeleteVertexArrays(1, &c.vao)
+	c.target.Release()
+	c.rows.Release()
+	c.Gatherer.Release()
+}
+
+// Gather counts the cells in the given state.
+func (c *RegionCounter) Gather(state *SimulationState, generation uint64) {
+	size := state.Size()
+	if err := c.target.Resize(c.Width(), int(size[1])); err != nil {
+		return
+	}
+
+	c.target.Draw(c.rows, c.vao, int32(c.Width()*6), state)
+	c.target.BindTexture()
+	c.Gatherer.Gather(state, generation)
+	c.target.UnbindTexture()
+}
+
+// readCellCounts decodes the counts read back by a RegionCounter.
+// They are ordered by generation, then by region.
+func readCellCounts(data []byte) []CellCounts {
+	const size = 16
+
+	counts := make([]CellCounts, len(data)/size)
+	for i := range counts {
+		texel := data[i*size:]
+		for j := range counts[i] {
+			counts[i][j] = int(binary.LittleEndian.Uint32(texel[j*4:]))
+		}
+	}
+
+	return counts
+}
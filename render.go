@@ -0,0 +1,224 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+
+	"github.com/hexaflex/wireworld-gpu/math"
+)
+
+// Output formats for the render command.
+const (
+	RenderPNG = "png"
+	RenderY4M = "y4m"
+)
+
+// MinGridZoom is the cell size, in pixels, from which grid lines are drawn.
+const MinGridZoom = 4
+
+var renderCommand = &Command{
+	Name:  "render",
+	Usage: "<image>",
+	Brief: "Renders simulation generations to a PNG sequence or Y4M video.",
+	Run:   runRender,
+}
+
+func runRender(cmd *Command, args []string) error {
+	var pal Palette
+	var camera CameraPath
+	pal.LoadDefault()
+	r := FrameRenderer{
+		Palette:   &pal,
+		GridColor: color.RGBA{0x20, 0x20, 0x20, 0xff},
+	}
+
+	fs := newFlagSet(cmd)
+	paletteFlags(fs, &pal)
+	format := fs.String("format", RenderPNG, "Output format: png or y4m.")
+	output := fs.String("o", "", "Output file, or - for stdout. For png, this is a pattern with a verb for the frame number. Defaults to frame%06d.png for png and stdout for y4m.")
+	start := fs.Int("start", 0, "First generation to render.")
+	frames := fs.Int("frames", 100, "Number of frames to render.")
+	every := fs.Int("every", 1, "Render every Nth generation.")
+	scale := fs.Int("scale", 1, "Size of a cell in pixels. Ignored if a camera path is used.")
+	cameraFile := fs.String("camera", "", "File with camera keyframes, one per line: <generation> <x> <y> <zoom>.")
+	fs.IntVar(&r.Width, "width", 0, "Frame width in pixels. Defaults to the simulation width times the scale.")
+	fs.IntVar(&r.Height, "height", 0, "Frame height in pixels. Defaults to the simulation height times the scale.")
+	fs.BoolVar(&r.Grid, "grid", false, fmt.Sprintf("Draw lines between cells which are at least %d pixels in size.", MinGridZoom))
+	fs.Var(colorValue{&r.GridColor}, "grid-color", "Color for grid lines.")
+	fps := fs.Int("fps", 30, "Frame rate of y4m output.")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("missing input image")
+	}
+
+	switch {
+	case *format != RenderPNG && *format != RenderY4M:
+		return fmt.Errorf("format must be %q or %q", RenderPNG, RenderY4M)
+	case *start < 0:
+		return errors.New("start must be >= 0")
+	case *frames <= 0 || *every <= 0 || *scale <= 0 || *fps <= 0:
+		return errors.New("frames, every, scale and fps must be > 0")
+	case r.Width < 0 || r.Height < 0:
+		return errors.New("width and height must be >= 0")
+	}
+
+	grid, err := LoadGrid(fs.Arg(0), &pal)
+	if err != nil {
+		return err
+	}
+
+	if len(*cameraFile) > 0 {
+		camera, err = LoadCameraPath(*cameraFile)
+		if err != nil {
+			return err
+		}
+	} else {
+		camera = CameraPath{{
+			Center: grid.Size().MulScalar(0.5),
+			Zoom:   float32(*scale),
+		}}
+	}
+
+	if r.Width == 0 {
+		r.Width = grid.Width * *scale
+	}
+
+	if r.Height == 0 {
+		r.Height = grid.Height * *scale
+	}
+
+	if len(*output) == 0 {
+		*output = StdStream
+		if *format == RenderPNG {
+			*output = "frame%06d.png"
+		}
+	}
+
+	// A Y4M stream, or PNG frames written to stdout, go into a single file.
+	// Otherwise each frame gets its own file.
+	var w io.WriteCloser
+	var y4m *Y4MWriter
+
+	if *format == RenderY4M || *output == StdStream {
+		w, err = createOutput(*output)
+		if err != nil {
+			return err
+		}
+		defer w.Close()
+
+		if *format == RenderY4M {
+			y4m = NewY4MWriter(w, r.Width, r.Height, *fps)
+		}
+	}
+
+	grid.Step(*start)
+
+	for i := 0; i < *frames; i++ {
+		gen := uint64(*start + i**every)
+		img := r.Render(grid, camera.At(gen))
+
+		switch {
+		case y4m != nil:
+			err = y4m.WriteFrame(img)
+		case w != nil:
+			err = writeSnapshot(w, img)
+		default:
+			err = writeFrame(fmt.Sprintf(*output, i), img)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		// Nothing is left to capture after the last frame.
+		if i < *frames-1 {
+			grid.Step(*every)
+		}
+	}
+
+	if y4m != nil {
+		if err = y4m.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if w != nil {
+		return w.Close()
+	}
+
+	return nil
+}
+
+// writeFrame writes img to the given PNG file.
+func writeFrame(file string, img *image.Paletted) error {
+	fd, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+
+	if err = writeSnapshot(fd, img); err != nil {
+		fd.Close()
+		return err
+	}
+
+	return fd.Close()
+}
+
+// FrameRenderer draws simulation state into images of a fixed size, as seen
+// through a camera. Cells get their colors through CellIndex, the same
+// palette mapping the display shader and saved snapshots use.
+type FrameRenderer struct {
+	Palette   *Palette   // Cell colors.
+	GridColor color.RGBA // Color for grid lines.
+	Width     int        // Image width in pixels.
+	Height    int        // Image height in pixels.
+	Grid      bool       // Draw lines between cells?
+}
+
+// Render draws the given grid as seen through camera cam.
+func (r *FrameRenderer) Render(g *Grid, cam Keyframe) *image.Paletted {
+	const indexGrid = IndexTail + 1
+
+	colors := append(r.Palette.Colors(), r.GridColor)
+	img := image.NewPaletted(image.Rect(0, 0, r.Width, r.Height), colors)
+	drawGrid := r.Grid && cam.Zoom >= MinGridZoom
+
+	// Cell coordinates of the top-left pixel.
+	origin := cam.Center.Sub(math.Vec2{float32(r.Width), float32(r.Height)}.MulScalar(0.5 / cam.Zoom))
+
+	cells := make([]int, r.Width)
+	for px := range cells {
+		cells[px] = int(math.Floor(origin[0] + float32(px)/cam.Zoom))
+	}
+
+	prevY := int(math.Floor(origin[1] - 1/cam.Zoom))
+
+	for py := 0; py < r.Height; py++ {
+		cy := int(math.Floor(origin[1] + float32(py)/cam.Zoom))
+		row := img.Pix[py*img.Stride:]
+
+		for px := 0; px < r.Width; px++ {
+			cx := cells[px]
+
+			if drawGrid && (cy != prevY || (px > 0 && cx != cells[px-1])) {
+				row[px] = indexGrid
+				continue
+			}
+
+			row[px] = CellIndex(g.At(cx, cy))
+		}
+
+		prevY = cy
+	}
+
+	return img
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hexaflex/wireworld-gpu/math"
+)
+
+// Rewind keeps earlier states of a simulation on the GPU, so it can step
+// backwards. Wireworld is not reversible, so this is done by keeping copies.
+//
+// Every one of the most recent generations is kept, up to a fixed number.
+// Older history is covered by checkpoints, taken every so many generations.
+// Generations between two checkpoints are restored by simulating forward
+// from the older one.
+//
+// All stored states are copies of the simulation as it actually ran. When
+// its cells are edited, the history no longer leads to the current state
+// and must be reset. The state it was reset to is kept as its base, which
+// is never replaced by newer generations.
+type Rewind struct {
+	size           math.Vec2
+	base           []*rewindEntry
+	recent         []*rewindEntry
+	checkpoints    []*rewindEntry
+	interval       uint64
+	nextCheckpoint uint64
+}
+
+// rewindEntry holds a copy of the simulation state at some generation.
+type rewindEntry struct {
+	state      SimulationState
+	generation uint64
+	valid      bool
+}
+
+// NewRewind creates a rewind buffer for simulations of the given size.
+// It keeps the given number of recent generations and checkpoints, with
+// a checkpoint taken every interval generations.
+//
+// Textures for the stored states are created as they are needed.
+func NewRewind(size math.Vec2, recent, checkpoints int, interval uint64) *Rewind {
+	if interval < 1 {
+		interval = 1
+	}
+
+	return &Rewind{
+		size:        size,
+		base:        make([]*rewindEntry, 1),
+		recent:      make([]*rewindEntry, recent),
+		checkpoints: make([]*rewindEntry, checkpoints),
+		interval:    interval,
+	}
+}
+
+// Release cleans up resources.
+func (r *Rewind) Release() {
+	for _, set := range [][]*rewindEntry{r.base, r.recent, r.checkpoints} {
+		for i, e := range set {
+			if e != nil {
+				e.state.Release()
+				set[i] = nil
+			}
+		}
+	}
+}
+
+// Size returns the dimensions of the stored states.
+func (r *Rewind) Size() math.Vec2 {
+	return r.size
+}
+
+// Reset discards all stored states and stores the current state of sim
+// as the base of the history.
+func (r *Rewind) Reset(sim *Simulation) {
+	for _, set := range [][]*rewindEntry{r.recent, r.checkpoints} {
+		for _, e := range set {
+			if e != nil {
+				e.valid = false
+			}
+		}
+	}
+
+	r.store(r.base, sim)
+	r.nextCheckpoint = sim.Generation()
+	r.Capture(sim)
+}
+
+// Base returns the state passed to the last Reset, along with its
+// generation. This is where the simulation was loaded or last edited.
+// Returns nil if there is none.
+func (r *Rewind) Base() (*SimulationState, uint64) {
+	e := r.base[0]
+	if e == nil || !e.valid {
+		return nil, 0
+	}
+	return &e.state, e.generation
+}
+
+// Next returns the next generation after gen which must be captured, when
+// the simulation is about to advance to generation end. The result is at
+// most end.
+func (r *Rewind) Next(gen, end uint64) uint64 {
+	next := end
+	if len(r.checkpoints) > 0 && r.nextCheckpoint > gen && r.nextCheckpoint < next {
+		next = r.nextCheckpoint
+	}
+
+	// Every generation is kept, but only the last ones before end
+	// matter. The rest would be overwritten before end is reached.
+	if n := uint64(len(r.recent)); n > 0 {
+		first := gen + 1
+		if end-gen > n {
+			first = end - n + 1
+		}
+		if first < next {
+			next = first
+		}
+	}
+
+	return next
+}
+
+// Capture stores the current state of sim, if its generation is needed.
+func (r *Rewind) Capture(sim *Simulation) {
+	gen := sim.Generation()
+
+	if len(r.checkpoints) > 0 && gen >= r.nextCheckpoint {
+		r.store(r.checkpoints, sim)
+		r.nextCheckpoint = gen + r.interval
+	}
+
+	if len(r.recent) > 0 {
+		r.store(r.recent, sim)
+	}
+}
+
+// store copies the state of sim into the entry of set with the oldest
+// generation, or an unused entry.
+func (r *Rewind) store(set []*rewindEntry, sim *Simulation) {
+	slot := 0
+	for i, e := range set {
+		if e == nil || !e.valid {
+			slot = i
+			break
+		}
+		if e.generation < set[slot].generation {
+			slot = i
+		}
+	}
+
+	e := set[slot]
+	if e == nil {
+		e = new(rewindEntry)
+		if err := e.state.Init(r.size); err != nil {
+			return
+		}
+		set[slot] = e
+	}
+
+	sim.SaveTo(&e.state)
+	e.generation = sim.Generation()
+	e.valid = true
+}
+
+// Oldest returns the oldest generation which can be restored.
+// Returns false if no states are stored.
+func (r *Rewind) Oldest() (uint64, bool) {
+	var oldest uint64
+	var ok bool
+
+	for _, set := range [][]*rewindEntry{r.recent, r.checkpoints} {
+		for _, e := range set {
+			if e != nil && e.valid && (!ok || e.generation < oldest) {
+				oldest = e.generation
+				ok = true
+			}
+		}
+	}
+
+	return oldest, ok
+}
+
+// Restore sets sim back to the given generation. States after that
+// generation are discarded, as the simulation runs forward from there
+// again.
+func (r *Rewind) Restore(sim *Simulation, gen uint64) error {
+	if gen >= sim.Generation() {
+		return nil
+	}
+
+	if e := r.find(r.recent, gen, true); e != nil {
+		sim.LoadFrom(&e.state, e.generation)
+		r.discardAfter(gen)
+		return nil
+	}
+
+	// Simulate forward from the closest earlier checkpoint. This also
+	// fills the recent generations again, so stepping further back from
+	// here does not need to simulate again.
+	cp := r.find(r.checkpoints, gen, false)
+	if cp == nil {
+		return fmt.Errorf("generation %d is no longer available", gen)
+	}
+
+	sim.LoadFrom(&cp.state, cp.generation)
+	r.discardAfter(cp.generation)
+	r.nextCheckpoint = cp.generation + r.interval
+
+	// The heatmap is not rewound. It already counted the generations
+	// simulated here, so they must not be counted again.
+	heat := sim.Heatmap()
+	sim.SetHeatmap(nil)
+
+	for sim.Generation() < gen {
+		next := r.Next(sim.Generation(), gen)
+		sim.Step(int(next - sim.Generation()))
+		r.Capture(sim)
+	}
+
+	sim.SetHeatmap(heat)
+
+	return nil
+}
+
+// find returns the entry in set with the given generation. If exact is
+// false, it returns the entry with the highest generation not after gen.
+// Returns nil if there is no such entry.
+func (r *Rewind) find(set []*rewindEntry, gen uint64, exact bool) *rewindEntry {
+	var out *rewindEntry
+	for _, e := range set {
+		if e == nil || !e.valid || e.generation > gen {
+			continue
+		}
+		if exact && e.generation != gen {
+			continue
+		}
+		if out == nil || e.generation > out.generation {
+			out = e
+		}
+	}
+	return out
+}
+
+// discardAfter discards all states after the given generation.
+func (r *Rewind) discardAfter(gen uint64) {
+	for _, set := range [][]*rewindEntry{r.recent, r.checkpoints} {
+		for _, e := range set {
+			if e != nil && e.generation > gen {
+				e.valid = false
+			}
+		}
+	}
+
+	if cp := r.find(r.checkpoints, gen, false); cp != nil {
+		r.nextCheckpoint = cp.generation + r.interval
+	} else {
+		r.nextCheckpoint = gen
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// RLE cell symbols, as used by Golly for its WireWorld rule.
+// The states are: 0 = empty, 1 = electron head, 2 = electron tail, 3 = wire.
+const (
+	rleEmpty = '.'
+	rleHead  = 'A'
+	rleTail  = 'B'
+	rleWire  = 'C'
+)
+
+// rleLineLength is the maximum length of lines written by writeRLE.
+const rleLineLength = 70
+
+// isRLE returns true if data looks like an RLE pattern.
+// These start with comment lines or the header line.
+func isRLE(data []byte) bool {
+	data = bytes.TrimLeft(data, " \t\r\n")
+	return len(data) > 0 && (data[0] == '#' || data[0] == 'x')
+}
+
+// readRLE reads a grid from an RLE pattern.
+//
+// This is the run length encoded format used by Golly and other cellular
+// automata tools. Both the multi-state symbols (. A B C) and the two-state
+// symbols (b o) are accepted. The latter treat live cells as electron heads.
+func readRLE(r io.Reader) (*Grid, error) {
+	var width, height int
+	var body strings.Builder
+	haveHeader := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case len(line) == 0 || strings.HasPrefix(line, "#"):
+			continue
+		case !haveHeader:
+			var err error
+			width, height, err = parseRLEHeader(line)
+			if err != nil {
+				return nil, err
+			}
+			haveHeader = true
+		default:
+			body.WriteString(line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if !haveHeader {
+		return nil, errors.New("rle: missing header")
+	}
+
+	g := NewGrid(width, height)
+	x, y, run := 0, 0, 0
+
+	for _, r := range body.String() {
+		if r >= '0' && r <= '9' {
+			run = run*10 + int(r-'0')
+			continue
+		}
+
+		n := run
+		if n == 0 {
+			n = 1
+		}
+		run = 0
+
+		var cell byte
+		switch r {
+		case rleEmpty, 'b':
+			cell = CellEmpty
+		case rleHead, 'o':
+			cell = CellHead
+		case rleTail:
+			cell = CellTail
+		case rleWire:
+			cell = CellWire
+		case '$':
+			x, y = 0, y+n
+			continue
+		case '!':
+			return g, nil
+		case ' ', '\t':
+			continue
+		default:
+			return nil, fmt.Errorf("rle: unexpected symbol %q", r)
+		}
+
+		for ; n > 0; n-- {
+			g.Set(x, y, cell)
+			x++
+		}
+	}
+
+	return g, nil
+}
+
+// parseRLEHeader parses the header line of an RLE pattern:
+// `x = <width>, y = <height>, rule = <rule>`.
+func parseRLEHeader(line string) (width, height int, err error) {
+	width, height = -1, -1
+
+	for _, field := range strings.Split(line, ",") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return 0, 0, fmt.Errorf("rle: invalid header %q", line)
+		}
+
+		key := strings.TrimSpace(kv[0])
+		value := strings.TrimSpace(kv[1])
+
+		switch key {
+		case "x":
+			width, err = strconv.Atoi(value)
+		case "y":
+			height, err = strconv.Atoi(value)
+		}
+
+		if err != nil {
+			return 0, 0, fmt.Errorf("rle: invalid header %q; %v", line, err)
+		}
+	}
+
+	if width < 1 || height < 1 {
+		return 0, 0, fmt.Errorf("rle: invalid dimensions in header %q", line)
+	}
+
+	return width, height, nil
+}
+
+// writeRLE writes g as an RLE pattern.
+func writeRLE(w io.Writer, g *Grid) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "x = %d, y = %d, rule = WireWorld\n", g.Width, g.Height)
+
+	var line []byte
+	emit := func(n int, symbol byte) {
+		var item []byte
+		if n > 1 {
+			item = strconv.AppendInt(item, int64(n), 10)
+		}
+		item = append(item, symbol)
+
+		if len(line)+len(item) > rleLineLength {
+			bw.Write(line)
+			bw.WriteByte('\n')
+			line = line[:0]
+		}
+		line = append(line, item...)
+	}
+
+	// Empty rows are skipped by repeating the end-of-row symbol.
+	cursor := 0
+	for y := 0; y < g.Height; y++ {
+		row := g.Pix[y*g.Width : (y+1)*g.Width]
+
+		// Trailing empty cells in a row are implied.
+		end := len(row)
+		for end > 0 && row[end-1] == CellEmpty {
+			end--
+		}
+
+		if end == 0 {
+			continue
+		}
+
+		if y > cursor {
+			emit(y-cursor, '$')
+			cursor = y
+		}
+
+		for x := 0; x < end; {
+			n := 1
+			for x+n < end && row[x+n] == row[x] {
+				n++
+			}
+			emit(n, rleSymbol(row[x]))
+			x += n
+		}
+	}
+
+	emit(1, '!')
+	bw.Write(line)
+	bw.WriteByte('\n')
+	return bw.Flush()
+}
+
+// rleSymbol returns the RLE symbol for the given cell state.
+func rleSymbol(cell byte) byte {
+	switch cell {
+	case CellWire:
+		return rleWire
+	case CellHead:
+		return rleHead
+	case CellTail:
+		return rleTail
+	default:
+		return rleEmpty
+	}
+}
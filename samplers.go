@@ -0,0 +1,61 @@
+package main
+
+// updateSamplers hands the active probe sampler, breakpoint evaluator,
+// cycle detection and population counts to the simulation.
+func (a *Application) updateSamplers() {
+	var samplers []Sampler
+	if a.probes != nil {
+		samplers = append(samplers, a.probes)
+	}
+	if a.evaluator != nil {
+		samplers = append(samplers, a.evaluator)
+	}
+	if a.cycles != nil {
+		samplers = append(samplers, a.cycles)
+	}
+	if a.population != nil {
+		samplers = append(samplers, a.population)
+	}
+	a.simulation.SetSamplers(samplers...)
+}
+
+// pollSamplers handles the data read back by the samplers.
+func (a *Application) pollSamplers() {
+	if a.probes != nil {
+		a.probes.Poll()
+	}
+
+	if a.evaluator != nil {
+		a.evaluator.Poll()
+		a.checkBreakpoints()
+	}
+
+	if a.cycles != nil {
+		a.cycles.Poll()
+		a.checkCycles()
+	}
+
+	if a.population != nil {
+		a.population.Poll()
+	}
+}
+
+// flushSamplers starts reading back the data gathered by the samplers
+// in this frame.
+func (a *Application) flushSamplers() {
+	if a.probes != nil {
+		a.probes.Flush()
+	}
+
+	if a.evaluator != nil {
+		a.evaluator.Flush()
+	}
+
+	if a.cycles != nil {
+		a.cycles.Flush()
+	}
+
+	if a.population != nil {
+		a.population.Flush()
+	}
+}
@@ -22,6 +22,16 @@ func (s Shader) SetUniformMat4(name string, mat math.Mat4) {
 	gl.UniformMatrix4fv(s.uniform(name), 1, false, &mat[0])
 }
 
+// SetUniformFloat sets the given uniform to the specified value.
+func (s Shader) SetUniformFloat(name string, v float32) {
+	gl.Uniform1f(s.uniform(name), v)
+}
+
+// SetUniformUint sets the given uniform to the specified value.
+func (s Shader) SetUniformUint(name string, v uint32) {
+	gl.Uniform1ui(s.uniform(name), v)
+}
+
 // SetUniformVec2 sets the given uniform to the specified value.
 func (s Shader) SetUniformVec2(name string, v math.Vec2) {
 	gl.Uniform2fv(s.uniform(name), 1, &v[0])
@@ -24,32 +24,65 @@ var DisplayShader = ShaderSource{
 		$INCLUDE_SHARED$
 
 		layout (binding = 0) uniform sampler2D input;
+		layout (binding = 1) uniform sampler2D overlay;
+		layout (binding = 2) uniform sampler2D heat;
+		layout (binding = 3) uniform usampler2D nets;
 
-		uniform vec4 PalEmpty;
-		uniform vec4 PalWire;
-		uniform vec4 PalHead;
-		uniform vec4 PalTail;
+		// Cell colors, ordered by palette index.
+		uniform vec4 Palette[4];
+
+		// Turns heatmap values into rates relative to the highest
+		// possible one. 0 disables the heatmap.
+		uniform float HeatScale;
+
+		// Net ID of the highlighted net, or 0 if there is none.
+		uniform uint HighlightNet;
+		uniform vec4 HighlightColor;
 
 		in  vec2 fragUV;
 		out vec4 output;
 
+		// heatColor returns the color of the given relative rate. This
+		// must match the function of the same name in heatmap.go.
+		vec3 heatColor(float rate) {
+			const vec3 stops[5] = vec3[](
+				vec3(0x20, 0x40, 0xff),
+				vec3(0x20, 0xd0, 0xd0),
+				vec3(0x40, 0xe0, 0x40),
+				vec3(0xff, 0xe0, 0x20),
+				vec3(0xff, 0x20, 0x20)) / 255;
+
+			float f = clamp(rate, 0, 1) * 4;
+			int i = min(int(f), 3);
+			return mix(stops[i], stops[i+1], f - float(i));
+		}
+
 		void main() {
 			uint cell = uint(texture2D(input, fragUV).r * 255);
 
-			switch (cell) {
-			case CellWire:
-				output = PalWire;
-				break;
-			case CellHead:
-				output = PalHead;
-				break;
-			case CellTail:
-				output = PalTail;
-				break;
-			default:
-				output = PalEmpty;
-				break;
+			output = Palette[cellIndex(cell)];
+
+			// The heatmap is drawn over the dimmed circuit.
+			if (HeatScale > 0) {
+				float rate = clamp(texture2D(heat, fragUV).r * HeatScale, 0, 1);
+				output.rgb *= 0.4;
+				if (rate > 0) {
+					output.rgb = mix(output.rgb, heatColor(rate), 0.5 + 0.5 * rate);
+				}
+			}
+
+			// The highlighted net stands out against everything else.
+			if (HighlightNet != 0) {
+				if (texture(nets, fragUV).r == HighlightNet) {
+					output.rgb = mix(output.rgb, HighlightColor.rgb, HighlightColor.a);
+				} else {
+					output.rgb *= 0.5;
+				}
 			}
+
+			// Tool previews and other markers are blended over the cells.
+			vec4 mark = texture2D(overlay, fragUV);
+			output = vec4(mix(output.rgb, mark.rgb, mark.a), output.a);
 		}
 		`,
 }
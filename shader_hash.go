@@ -0,0 +1,102 @@
+package main
+
+// HashRowShader defines shader sources for the first pass of the state
+// hash. It draws one fragment per row of cells, which hashes the row and
+// counts its heads and tails.
+var HashRowShader = ShaderSource{
+	Vertex: `
+		#version 420
+
+		void main() {
+			// A triangle which covers the whole viewport.
+			vec2 pos = vec2((gl_VertexID << 1) & 2, gl_VertexID & 2);
+			gl_Position = vec4(pos * 2 - 1, 0, 1);
+		}
+		`,
+	Fragment: `
+		#version 420
+
+		$INCLUDE_SHARED$
+		` + shaderHash + `
+
+		layout (binding = 0) uniform sampler2D cells;
+
+		layout(location = 0) out uvec4 result;
+
+		void main() {
+			int y = int(gl_FragCoord.y);
+			int width = textureSize(cells, 0).x;
+
+			uvec2 h = HashSeed;
+			uint active = 0;
+
+			for (int x = 0; x < width; x++) {
+				uint cell = uint(texelFetch(cells, ivec2(x, y), 0).r * 255 + 0.5);
+				h = hash(h, cell);
+				if (cell == CellHead || cell == CellTail) {
+					active++;
+				}
+			}
+
+			result = uvec4(h, active, 0);
+		}
+		`,
+}
+
+// HashShader defines shader sources for the second pass of the state hash.
+// It draws a single point, which combines the row hashes and counts into a
+// texel of the gather texture.
+var HashShader = ShaderSource{
+	Vertex: `
+		#version 420
+
+		// Gather texture width and height, and the row to draw into.
+		uniform vec3 Target;
+
+		layout(location = 0) in float unused;
+
+		void main() {
+			vec2 texel = vec2(gl_VertexID, Target.z) + 0.5;
+			gl_Position = vec4(texel / Target.xy * 2 - 1, 0, 1);
+		}
+		`,
+	Fragment: `
+		#version 420
+
+		$INCLUDE_SHARED$
+		` + shaderHash + `
+
+		layout (binding = 1) uniform usampler2D rows;
+
+		layout(location = 0) out uvec4 result;
+
+		void main() {
+			int height = textureSize(rows, 0).y;
+
+			uvec2 h = HashSeed;
+			uint active = 0;
+
+			for (int y = 0; y < height; y++) {
+				uvec4 row = texelFetch(rows, ivec2(0, y), 0);
+				h = hash(hash(h, row.x), row.y);
+				active += row.z;
+			}
+
+			result = uvec4(h, active, 0);
+		}
+		`,
+}
+
+// shaderHash defines the hash function used by the hash shaders. It keeps
+// two independent 32 bit hashes, which together make a 64 bit hash. It is
+// inserted after the shared source, like a second include.
+const shaderHash = `
+	const uvec2 HashSeed = uvec2(2166136261u, 0x9e3779b9u);
+
+	uvec2 hash(uvec2 h, uint v) {
+		h.x = (h.x ^ v) * 16777619u;
+		h.y = ((h.y << 5) | (h.y >> 27)) ^ v;
+		h.y *= 0x27d4eb2du;
+		return h;
+	}
+	`
@@ -0,0 +1,35 @@
+package main
+
+// PanelShader defines shader sources for panels drawn in screen space.
+var PanelShader = ShaderSource{
+	Vertex: `
+		#version 420
+
+		$INCLUDE_SHARED$
+
+		uniform mat4 Model;
+
+		in  vec2 vertPos;
+		in  vec2 vertUV;
+		out vec2 fragUV;
+
+		void main() {
+			gl_Position = Projection * View * Model * vec4(vertPos, 0, 1);
+			fragUV = vertUV;
+		}
+		`,
+	Fragment: `
+		#version 420
+
+		$INCLUDE_SHARED$
+
+		layout (binding = 0) uniform sampler2D image;
+
+		in  vec2 fragUV;
+		out vec4 output;
+
+		void main() {
+			output = texture2D(image, fragUV);
+		}
+		`,
+}
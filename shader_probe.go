@@ -0,0 +1,41 @@
+package main
+
+// ProbeShader defines shader sources for gathering the states of probed
+// cells into a row of the probe texture. It draws one point per probe.
+var ProbeShader = ShaderSource{
+	Vertex: `
+		#version 420
+
+		layout (binding = 0) uniform sampler2D cells;
+
+		// Probe texture width and height, and the row to draw into.
+		uniform vec3 Target;
+
+		layout(location = 0) in vec2 cellPos;
+		flat out float state;
+
+		void main() {
+			ivec2 pos = ivec2(cellPos);
+			ivec2 size = textureSize(cells, 0);
+
+			state = 0;
+			if (all(greaterThanEqual(pos, ivec2(0))) && all(lessThan(pos, size))) {
+				state = texelFetch(cells, pos, 0).r;
+			}
+
+			// Place the point in the center of texel (probe, row).
+			vec2 texel = vec2(gl_VertexID, Target.z) + 0.5;
+			gl_Position = vec4(texel / Target.xy * 2 - 1, 0, 1);
+		}
+		`,
+	Fragment: `
+		#version 420
+
+		flat in  float state;
+		out vec4 output;
+
+		void main() {
+			output = vec4(state, 0, 0, 1);
+		}
+		`,
+}
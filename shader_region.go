@@ -0,0 +1,86 @@
+package main
+
+// RegionRowShader defines shader sources for the first pass of a region
+// count. It draws one column per counted region, in which each fragment
+// counts the cells of the region in one row of cells, by palette index.
+var RegionRowShader = ShaderSource{
+	Vertex: `
+		#version 420
+
+		layout(location = 0) in vec2 pos;
+		layout(location = 1) in vec4 rect;
+		flat out ivec4 fragRect;
+
+		void main() {
+			fragRect = ivec4(rect);
+			gl_Position = vec4(pos, 0, 1);
+		}
+		`,
+	Fragment: `
+		#version 420
+
+		$INCLUDE_SHARED$
+
+		layout (binding = 0) uniform sampler2D cells;
+
+		flat in ivec4 fragRect;
+		layout(location = 0) out uvec4 result;
+
+		void main() {
+			int y = int(gl_FragCoord.y);
+			result = uvec4(0);
+
+			if (y < fragRect.y || y >= fragRect.y + fragRect.w) {
+				return;
+			}
+
+			int width = textureSize(cells, 0).x;
+			int lo = clamp(fragRect.x, 0, width);
+			int hi = clamp(fragRect.x + fragRect.z, 0, width);
+
+			for (int x = lo; x < hi; x++) {
+				uint cell = uint(texelFetch(cells, ivec2(x, y), 0).r * 255 + 0.5);
+				result[cellIndex(cell)]++;
+			}
+		}
+		`,
+}
+
+// RegionShader defines shader sources for the second pass of a region
+// count. It draws one point per counted region, which adds up the counts
+// of the region's rows.
+var RegionShader = ShaderSource{
+	Vertex: `
+		#version 420
+
+		// Gather texture width and height, and the row to draw into.
+		uniform vec3 Target;
+
+		layout(location = 0) in float column;
+		flat out int fragColumn;
+
+		void main() {
+			fragColumn = int(column);
+
+			vec2 texel = vec2(gl_VertexID, Target.z) + 0.5;
+			gl_Position = vec4(texel / Target.xy * 2 - 1, 0, 1);
+		}
+		`,
+	Fragment: `
+		#version 420
+
+		layout (binding = 1) uniform usampler2D rows;
+
+		flat in int fragColumn;
+		layout(location = 0) out uvec4 result;
+
+		void main() {
+			int height = textureSize(rows, 0).y;
+
+			result = uvec4(0);
+			for (int y = 0; y < height; y++) {
+				result += texelFetch(rows, ivec2(fragColumn, y), 0);
+			}
+		}
+		`,
+}
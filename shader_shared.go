@@ -14,4 +14,16 @@ const ShaderShared = `
 	const uint CellWire  = 50;
 	const uint CellTail  = 100;
 	const uint CellHead  = 255;
+
+	// cellIndex returns the palette index of the given cell state.
+	//
+	// This needs to stay in sync with CellIndex in palette.go
+	uint cellIndex(uint cell) {
+		switch (cell) {
+		case CellWire: return 1;
+		case CellHead: return 2;
+		case CellTail: return 3;
+		default:       return 0;
+		}
+	}
 	`
@@ -23,7 +23,11 @@ var SimulationShader = ShaderSource{
 		layout (binding = 0) uniform sampler2D input;
 
 		in  vec2 fragUV;
-		out vec4 output;
+		layout(location = 0) out vec4 output;
+
+		// Heads of the new generation, added to the heatmap, if the
+		// simulation has one. Otherwise it is discarded.
+		layout(location = 1) out float heat;
 
 		// countHeadNeighbours checks texels surrounding fragUV and
 		// counts those which have the cellHead state.
@@ -74,6 +78,7 @@ var SimulationShader = ShaderSource{
 			}
 
 			output = vec4(float(cell) / 255, 0, 0, 1);
+			heat = cell == CellHead ? 1 : 0;
 		}
 		`,
 }
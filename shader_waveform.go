@@ -0,0 +1,31 @@
+package main
+
+// WaveformShader defines shader sources for the waveform panel. It draws
+// flat colored geometry in waveform coordinates, where one unit along x is
+// a generation and one unit along y is a lane.
+var WaveformShader = ShaderSource{
+	Vertex: `
+		#version 420
+
+		uniform mat4 Projection;
+
+		layout(location = 0) in vec2 vertPos;
+		layout(location = 1) in vec4 vertColor;
+		out vec4 fragColor;
+
+		void main() {
+			gl_Position = Projection * vec4(vertPos, 0, 1);
+			fragColor = vertColor;
+		}
+		`,
+	Fragment: `
+		#version 420
+
+		in  vec4 fragColor;
+		out vec4 output;
+
+		void main() {
+			output = fragColor;
+		}
+		`,
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"image"
+)
+
+// linePoints returns the cells on the line from a to b, inclusive.
+//
+// This uses Bresenham's algorithm, which yields an 8-connected line.
+// Consecutive cells touch by at least a corner, which is all a wire
+// needs to carry a signal.
+func linePoints(a, b image.Point) []image.Point {
+	dx, sx := abs(b.X-a.X), sign(b.X-a.X)
+	dy, sy := -abs(b.Y-a.Y), sign(b.Y-a.Y)
+	e := dx + dy
+
+	out := make([]image.Point, 0, max(dx, -dy)+1)
+	for p := a; ; {
+		out = append(out, p)
+		if p == b {
+			return out
+		}
+
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			p.X += sx
+		}
+		if e2 <= dx {
+			e += dx
+			p.Y += sy
+		}
+	}
+}
+
+// rectPoints returns the cells of the rectangle spanned by corners a and b,
+// inclusive. If filled is false, only the outline is returned.
+func rectPoints(a, b image.Point, filled bool) []image.Point {
+	r := spanRect(a, b)
+
+	var out []image.Point
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			edge := x == r.Min.X || x == r.Max.X-1 || y == r.Min.Y || y == r.Max.Y-1
+			if filled || edge {
+				out = append(out, image.Pt(x, y))
+			}
+		}
+	}
+
+	return out
+}
+
+// spanRect returns the rectangle spanned by corners a and b, inclusive.
+func spanRect(a, b image.Point) image.Rectangle {
+	r := image.Rectangle{a, b}.Canon()
+	r.Max = r.Max.Add(image.Pt(1, 1))
+	return r
+}
+
+// FloodRegion returns the connected region of cells which share the state of
+// the cell at start. Wire, head and tail cells connect through all eight of
+// their neighbours, like the simulation rules do. Empty cells only connect
+// horizontally and vertically, so a fill does not leak through diagonal wires.
+func (g *Grid) FloodRegion(start image.Point) []image.Point {
+	if !start.In(g.Bounds()) {
+		return nil
+	}
+
+	cell := g.At(start.X, start.Y)
+	neighbours := neighbours8
+	if cell == CellEmpty {
+		neighbours = neighbours4
+	}
+
+	seen := make([]bool, len(g.Pix))
+	seen[start.Y*g.Width+start.X] = true
+	stack := []image.Point{start}
+	var out []image.Point
+
+	for len(stack) > 0 {
+		p := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		out = append(out, p)
+
+		for _, d := range neighbours {
+			n := p.Add(d)
+			if !n.In(g.Bounds()) || g.At(n.X, n.Y) != cell {
+				continue
+			}
+
+			i := n.Y*g.Width + n.X
+			if !seen[i] {
+				seen[i] = true
+				stack = append(stack, n)
+			}
+		}
+	}
+
+	return out
+}
+
+// Offsets to the neighbours of a cell.
+var (
+	neighbours4 = []image.Point{{0, -1}, {-1, 0}, {1, 0}, {0, 1}}
+	neighbours8 = []image.Point{
+		{-1, -1}, {0, -1}, {1, -1},
+		{-1, 0}, {1, 0},
+		{-1, 1}, {0, 1}, {1, 1},
+	}
+)
+
+// pointsBounds returns the smallest rectangle containing all given points.
+func pointsBounds(points []image.Point) image.Rectangle {
+	var r image.Rectangle
+	for i, p := range points {
+		pr := image.Rectangle{p, p.Add(image.Pt(1, 1))}
+		if i == 0 {
+			r = pr
+		} else {
+			r = r.Union(pr)
+		}
+	}
+	return r
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func sign(v int) int {
+	switch {
+	case v < 0:
+		return -1
+	case v > 0:
+		return 1
+	}
+	return 0
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
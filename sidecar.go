@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// sidecar collects the entries of a sidecar file: a text file which sits
+// next to a circuit file and adds to it, like its probes or breakpoints.
+type sidecar interface {
+	// add parses the fields of a line and adds the entry it defines.
+	add(fields []string) error
+}
+
+// sidecarFile returns the name of the sidecar file with the given
+// extension, which belongs to the given circuit file. Returns an empty
+// string if file is StdStream.
+func sidecarFile(file, ext string) string {
+	if len(file) == 0 || file == StdStream {
+		return ""
+	}
+	return file + ext
+}
+
+// loadSidecar reads the entries of the named sidecar file into s. Kind
+// names the entries in error messages.
+func loadSidecar(file, kind string, s sidecar) error {
+	data, err := readInput(file)
+	if err != nil {
+		return err
+	}
+	return readSidecar(bytes.NewReader(data), kind, s)
+}
+
+// loadSidecarFor reads the entries of the given circuit file into s, from
+// the named sidecar file. If that is empty, they are read from the
+// circuit's own sidecar file with extension ext, if there is one.
+func loadSidecarFor(input, file, ext, kind string, s sidecar) error {
+	if len(file) > 0 {
+		return loadSidecar(file, kind, s)
+	}
+
+	file = sidecarFile(input, ext)
+	if len(file) == 0 {
+		return nil
+	}
+
+	if err := loadSidecar(file, kind, s); !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// readSidecar hands the fields of each line in r to s. Empty lines and
+// lines starting with # are ignored.
+func readSidecar(r io.Reader, kind string, s sidecar) error {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		if err := s.add(strings.Fields(text)); err != nil {
+			return fmt.Errorf("%s line %d: %v", kind, line, err)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// saveSidecar writes the given lines to a sidecar file.
+func saveSidecar(file string, lines []string) error {
+	fd, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(fd)
+	for _, line := range lines {
+		fmt.Fprintln(w, line)
+	}
+
+	if err := w.Flush(); err != nil {
+		fd.Close()
+		return err
+	}
+
+	return fd.Close()
+}
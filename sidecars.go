@@ -0,0 +1,38 @@
+package main
+
+import (
+	"log"
+	"os"
+)
+
+// sidecarFile returns the name of a sidecar file for the input: the one
+// configured, if set, or else the input's own file with extension ext.
+// Returns an empty string if there is none, as for input read from stdin.
+func (a *Application) sidecarFile(configured, ext string) string {
+	if len(configured) > 0 {
+		return configured
+	}
+	return sidecarFile(a.config.Input, ext)
+}
+
+// loadSidecar loads the sidecar file of the given kind for the input, if
+// it has one. Function load reads the file and returns the number of
+// entries it holds.
+func (a *Application) loadSidecar(kind, configured, ext string, load func(file string) (int, error)) {
+	file := a.sidecarFile(configured, ext)
+	if len(file) == 0 {
+		return
+	}
+
+	n, err := load(file)
+	if err != nil {
+		// Most circuits have no sidecar files. That is only worth
+		// mentioning if one was asked for.
+		if !os.IsNotExist(err) || len(configured) > 0 {
+			log.Printf("failed to load %s: %v", kind, err)
+		}
+		return
+	}
+
+	log.Printf("loaded %d %s from %s", n, kind, file)
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"bytes"
+	"compress/flate"
+	"errors"
 	"image"
-	"os"
+	"io"
 
 	_ "image/gif"
 	_ "image/jpeg"
@@ -16,11 +19,15 @@ import (
 
 // Simulation implements the GPU driven wireworld simulation.
 type Simulation struct {
-	shader Shader
-	input  SimulationState
-	output SimulationState
-	vao    uint32
-	vbo    uint32
+	shader      Shader
+	input       SimulationState
+	output      SimulationState
+	annotations *Annotations
+	samplers    []Sampler
+	heatmap     *Heatmap
+	generation  uint64
+	vao         uint32
+	vbo         uint32
 }
 
 // NewSimulation creates a new, empty simulation with the given dimensions.
@@ -68,35 +75,130 @@ func NewSimulation(size math.Vec2) (*Simulation, error) {
 	return &s, nil
 }
 
-// LoadSimulation loads a simulation from the given image file.
-// Supported formats: PNG, JPG, GIF, PNM
+// LoadSimulation loads a simulation from the given file.
+// Supported formats: PNG, JPG, GIF, PNM, RLE and JSON manifests
 //
-// It uses the given color palette to recognize cell states.
+// It uses the given color palette to recognize cell states. Pixels which
+// match no palette color are kept as annotations for use in snapshots.
 func LoadSimulation(file string, pal *Palette) (*Simulation, error) {
-	fd, err := os.Open(file)
+	pix, size, ann, err := loadCells(file, pal)
 	if err != nil {
 		return nil, err
 	}
 
-	img, _, err := image.Decode(fd)
-	fd.Close()
+	sim, err := NewSimulation(size)
 	if err != nil {
 		return nil, err
 	}
 
-	b := img.Bounds()
-	size := math.Vec2{float32(b.Dx()), float32(b.Dy())}
-	sim, err := NewSimulation(size)
+	// Set the input buffer to the image data.
+	sim.input.SetData(pix, size)
+	sim.annotations = ann
+	return sim, nil
+}
+
+// Snapshot holds a compressed copy of a simulation state in client memory.
+// It can be turned back into a simulation with Restore.
+type Snapshot struct {
+	data        []byte // Cells in the internal format, flate compressed.
+	size        math.Vec2
+	annotations *Annotations
+	generation  uint64
+}
+
+// Snapshot copies the current simulation state into client memory.
+// This waits for the GPU.
+func (s *Simulation) Snapshot() (*Snapshot, error) {
+	rb := s.ReadAsync()
+	pix := rb.Wait()
+	rb.Release()
+
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestSpeed)
 	if err != nil {
 		return nil, err
 	}
 
-	// Set the input buffer to the image data.
-	pix, size := pal.toInternalFormat(img)
-	sim.input.SetData(pix, size)
+	if _, err = w.Write(pix); err != nil {
+		return nil, err
+	}
+
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+
+	return &Snapshot{
+		data:        buf.Bytes(),
+		size:        s.Size(),
+		annotations: s.annotations,
+		generation:  s.generation,
+	}, nil
+}
+
+// Len returns the number of bytes used by the snapshot's cell data.
+func (ss *Snapshot) Len() int {
+	return len(ss.data)
+}
+
+// Restore creates a new simulation with the state held by the snapshot.
+func (ss *Snapshot) Restore() (*Simulation, error) {
+	pix := make([]byte, int(ss.size[0])*int(ss.size[1]))
+	if _, err := io.ReadFull(flate.NewReader(bytes.NewReader(ss.data)), pix); err != nil {
+		return nil, err
+	}
+
+	sim, err := NewSimulation(ss.size)
+	if err != nil {
+		return nil, err
+	}
+
+	sim.input.SetData(pix, ss.size)
+	sim.annotations = ss.annotations
+	sim.generation = ss.generation
 	return sim, nil
 }
 
+// loadCells loads the given file and returns its contents in the internal
+// 8bpp format, along with its dimensions and annotations. The format is
+// detected from the file contents. If file is StdStream, this reads from
+// stdin.
+func loadCells(file string, pal *Palette) ([]byte, math.Vec2, *Annotations, error) {
+	return loadNestedCells(file, pal, nil)
+}
+
+// loadNestedCells is loadCells for a file which is included by the given
+// manifests. These are used to detect manifests which include themselves.
+func loadNestedCells(file string, pal *Palette, parents []string) ([]byte, math.Vec2, *Annotations, error) {
+	data, err := readInput(file)
+	if err != nil {
+		return nil, math.Vec2{}, nil, err
+	}
+
+	if isManifest(data) {
+		a, err := loadManifest(file, data, pal, parents)
+		if err != nil {
+			return nil, math.Vec2{}, nil, err
+		}
+		return a.Cells.Pix, a.Cells.Size(), a.Annotations, nil
+	}
+
+	if isRLE(data) {
+		g, err := readRLE(bytes.NewReader(data))
+		if err != nil {
+			return nil, math.Vec2{}, nil, err
+		}
+		return g.Pix, g.Size(), nil, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, math.Vec2{}, nil, err
+	}
+
+	pix, size := pal.toInternalFormat(img)
+	return pix, size, pal.annotations(img), nil
+}
+
 // Release unloads simulator resources.
 func (s *Simulation) Release() {
 	gl.DeleteBuffers(1, &s.vbo)
@@ -111,15 +213,183 @@ func (s *Simulation) Size() math.Vec2 {
 	return s.output.Size()
 }
 
-// Image returns the current simulation state as an image,
-// colored using the given palette. Note that this uses
-// glReadPixels and consequently is rather slow. Use it sparingly.
-func (s *Simulation) Image(pal *Palette) image.Image {
+// Annotations returns the annotations found in the input image.
+// Returns nil if there are none.
+func (s *Simulation) Annotations() *Annotations {
+	return s.annotations
+}
+
+// ReadAsync starts an asynchronous read of the current simulation state.
+// The returned readback yields the state in the internal 8bpp format.
+func (s *Simulation) ReadAsync() *Readback {
 	// We read from input because the render function sets
 	// this to the most recent simulation state.
-	size := s.input.Size()
-	pix := s.input.Data()
-	return pal.fromInternalFormat(pix, size)
+	return s.input.ReadAsync()
+}
+
+// Resize changes the simulation bounds to r, given in the current cell
+// coordinates. The top-left corner of r becomes the new origin. Cells
+// inside both the old and new bounds keep their state, new cells are
+// empty. Both state buffers are reallocated.
+func (s *Simulation) Resize(r image.Rectangle) error {
+	if r.Empty() {
+		return errors.New("simulation: invalid dimensions")
+	}
+
+	var input, output SimulationState
+	size := math.Vec2{float32(r.Dx()), float32(r.Dy())}
+
+	if err := input.Init(size); err != nil {
+		input.Release()
+		return err
+	}
+
+	if err := output.Init(size); err != nil {
+		input.Release()
+		output.Release()
+		return err
+	}
+
+	input.Clear()
+	if src := r.Intersect(s.Bounds()); !src.Empty() {
+		input.CopyRegion(&s.input, src, src.Min.Sub(r.Min))
+	}
+
+	s.input.Release()
+	s.output.Release()
+	s.input, s.output = input, output
+
+	if s.annotations != nil {
+		s.annotations = s.annotations.Crop(r)
+	}
+
+	return nil
+}
+
+// SaveTo copies the current simulation state into dst, which must have
+// the same dimensions as the simulation.
+func (s *Simulation) SaveTo(dst *SimulationState) {
+	dst.CopyFrom(&s.input)
+}
+
+// LoadFrom replaces the current simulation state with the contents of src,
+// which holds the state at the given generation.
+func (s *Simulation) LoadFrom(src *SimulationState, generation uint64) {
+	s.input.CopyFrom(src)
+	s.generation = generation
+}
+
+// Cells reads the cells in the given region of the current simulation
+// state. The region is clipped to the simulation bounds. This waits for
+// the GPU, so it is meant for small regions and one-off reads.
+func (s *Simulation) Cells(r image.Rectangle) *Grid {
+	r = r.Intersect(s.Bounds())
+	g := NewGrid(r.Dx(), r.Dy())
+	if r.Empty() {
+		return g
+	}
+
+	rb := s.input.ReadRegionAsync(r.Min.X, r.Min.Y, r.Dx(), r.Dy())
+	copy(g.Pix, rb.Wait())
+	rb.Release()
+	return g
+}
+
+// Generation returns the number of generations simulated since the
+// simulation was loaded.
+func (s *Simulation) Generation() uint64 {
+	return s.generation
+}
+
+// Bounds returns the cell dimensions of the simulation as a rectangle.
+func (s *Simulation) Bounds() image.Rectangle {
+	size := s.Size()
+	return image.Rect(0, 0, int(size[0]), int(size[1]))
+}
+
+// SetCells overwrites the cells in the current simulation state with the
+// contents of g, placed at the given position. Cells which fall outside
+// the simulation are ignored.
+func (s *Simulation) SetCells(pos image.Point, g *Grid) {
+	dst := g.Bounds().Add(pos).Intersect(s.Bounds())
+	if dst.Empty() {
+		return
+	}
+
+	if dst.Size() != g.Bounds().Size() {
+		g = g.Crop(dst.Sub(pos))
+	}
+
+	s.input.SetRegion(dst.Min.X, dst.Min.Y, dst.Dx(), dst.Dy(), g.Pix)
+}
+
+// SetCellsMasked is like SetCells, but only writes the cells of g whose
+// entry in mask is set. The mask has one entry per cell of g, in the same
+// order. Consecutive cells in a row are written together. This allows
+// editing scattered cells without reading back the cells in between. A nil
+// mask writes all cells.
+func (s *Simulation) SetCellsMasked(pos image.Point, g *Grid, mask []bool) {
+	if mask == nil {
+		s.SetCells(pos, g)
+		return
+	}
+
+	bounds := s.Bounds()
+	for y := 0; y < g.Height; y++ {
+		row := y * g.Width
+		for x := 0; x < g.Width; {
+			if !mask[row+x] {
+				x++
+				continue
+			}
+
+			end := x + 1
+			for end < g.Width && mask[row+end] {
+				end++
+			}
+
+			r := image.Rect(x, y, end, y+1).Add(pos).Intersect(bounds)
+			if !r.Empty() {
+				src := r.Min.Sub(pos)
+				i := src.Y*g.Width + src.X
+				s.input.SetRegion(r.Min.X, r.Min.Y, r.Dx(), 1, g.Pix[i:i+r.Dx()])
+			}
+
+			x = end
+		}
+	}
+}
+
+// CellsAsync starts reading the cells in region r of the current state.
+// Unlike Cells, this does not wait for the GPU. The region must lie within
+// the simulation bounds.
+func (s *Simulation) CellsAsync(r image.Rectangle) *Readback {
+	return s.input.ReadRegionAsync(r.Min.X, r.Min.Y, r.Dx(), r.Dy())
+}
+
+// SetHeatmap sets the heatmap which counts the heads of every generation,
+// or nil to stop counting. It must have the simulation's dimensions. The
+// simulation does not take ownership of it.
+func (s *Simulation) SetHeatmap(h *Heatmap) {
+	var tex uint32
+	if h != nil {
+		tex = h.tex
+	}
+
+	s.heatmap = h
+	s.input.AttachHeatmap(tex)
+	s.output.AttachHeatmap(tex)
+}
+
+// Heatmap returns the heatmap set with SetHeatmap, or nil if there is none.
+func (s *Simulation) Heatmap() *Heatmap {
+	return s.heatmap
+}
+
+// SetSamplers sets the samplers which gather data after every generation.
+// The simulation does not take ownership of them.
+func (s *Simulation) SetSamplers(samplers ...Sampler) {
+	s.samplers = samplers
 }
 
 // Bind binds the current simulation state's texture, so it may be
@@ -138,9 +408,23 @@ func (s *Simulation) Step(n int) {
 	if n < 1 {
 		return
 	}
-	s.shader.Use()
+
+	// The samplers need the state we start from, unless they already
+	// have it from the previous step.
+	for _, sm := range s.samplers {
+		if !sm.Expects(s.generation) {
+			sm.Gather(&s.input, s.generation)
+		}
+	}
+
+	if s.heatmap != nil {
+		s.input.SetDrawBuffers(true)
+		s.output.SetDrawBuffers(true)
+		s.heatmap.beginStep()
+	}
 
 	size := s.input.Size()
+	s.shader.Use()
 	gl.Viewport(0, 0, int32(size[0]), int32(size[1]))
 	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
 	gl.BindVertexArray(s.vao)
@@ -158,8 +442,29 @@ func (s *Simulation) Step(n int) {
 		// Swap the states around. So the output of this pass
 		// becomes the input of the next pass.
 		s.output, s.input = s.input, s.output
+
+		if s.heatmap != nil {
+			s.heatmap.counted()
+		}
+
+		if len(s.samplers) > 0 {
+			for _, sm := range s.samplers {
+				sm.Gather(&s.input, s.generation+uint64(i)+1)
+			}
+			s.shader.Use()
+			gl.Viewport(0, 0, int32(size[0]), int32(size[1]))
+			gl.BindVertexArray(s.vao)
+		}
 	}
 
+	s.generation += uint64(n)
+
 	gl.BindVertexArray(0)
 	s.shader.Unuse()
+
+	if s.heatmap != nil {
+		s.heatmap.endStep()
+		s.input.SetDrawBuffers(false)
+		s.output.SetDrawBuffers(false)
+	}
 }
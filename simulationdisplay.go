@@ -1,6 +1,8 @@
 package main
 
 import (
+	"fmt"
+	"image"
 	"image/color"
 
 	"github.com/go-gl/gl/v4.2-core/gl"
@@ -88,6 +90,38 @@ func (d *SimulationDisplay) Zoom(delta float32, focus math.Vec2) {
 	d.SetScroll(xy2)
 }
 
+// ZoomFactor returns the current zoom level.
+func (d *SimulationDisplay) ZoomFactor() float32 {
+	return d.zoomFactor
+}
+
+// ScreenToCell returns the simulation cell coordinates at the given screen
+// position. The result is fractional; its integer part selects the cell.
+// The top-left cell is at (0, 0).
+func (d *SimulationDisplay) ScreenToCell(pos math.Vec2) math.Vec2 {
+	// The quad is centered on the translation offset, so the cell
+	// coordinates are relative to its center.
+	origin := d.transform.Translate.Add(math.Vec2{0.375, 0.375})
+	size := d.transform.Scale
+	return pos.Sub(origin).DivScalar(d.zoomFactor).Add(size.MulScalar(0.5))
+}
+
+// VisibleCells returns the rectangle of cells which are visible in the given
+// viewport, clipped to the simulation bounds.
+func (d *SimulationDisplay) VisibleCells(view math.Vec2) image.Rectangle {
+	min := d.ScreenToCell(math.Vec2{0, 0})
+	max := d.ScreenToCell(view)
+	size := d.transform.Scale
+
+	r := image.Rect(
+		int(math.Floor(min[0])),
+		int(math.Floor(min[1])),
+		int(math.Ceil(max[0])),
+		int(math.Ceil(max[1])),
+	)
+	return r.Intersect(image.Rect(0, 0, int(size[0]), int(size[1])))
+}
+
 // SetZoom sets the map's zoom level.
 func (d *SimulationDisplay) SetZoom(z float32) {
 	d.zoomFactor = math.Clamp(z, MinZoom, MaxZoom)
@@ -130,10 +164,31 @@ func (d *SimulationDisplay) SetPalette(pal *Palette) {
 	}
 
 	d.shader.Use()
-	d.shader.SetUniformVec4("PalEmpty", toVec4(pal.Empty))
-	d.shader.SetUniformVec4("PalWire", toVec4(pal.Wire))
-	d.shader.SetUniformVec4("PalHead", toVec4(pal.Head))
-	d.shader.SetUniformVec4("PalTail", toVec4(pal.Tail))
+	for i, c := range pal.Colors() {
+		d.shader.SetUniformVec4(fmt.Sprintf("Palette[%d]", i), toVec4(c.(color.RGBA)))
+	}
+	d.shader.Unuse()
+}
+
+// SetHeatScale sets the factor which turns heatmap values into rates
+// relative to HeatRate. A scale of 0 hides the heatmap.
+func (d *SimulationDisplay) SetHeatScale(scale float32) {
+	d.shader.Use()
+	d.shader.SetUniformFloat("HeatScale", scale)
+	d.shader.Unuse()
+}
+
+// SetHighlight sets the ID of the net to highlight, and the color to
+// blend over its cells. An ID of 0 highlights nothing.
+func (d *SimulationDisplay) SetHighlight(net int, c color.RGBA) {
+	d.shader.Use()
+	d.shader.SetUniformUint("HighlightNet", uint32(net))
+	d.shader.SetUniformVec4("HighlightColor", math.Vec4{
+		float32(c.R) / 255,
+		float32(c.G) / 255,
+		float32(c.B) / 255,
+		float32(c.A) / 255,
+	})
 	d.shader.Unuse()
 }
 
@@ -143,7 +198,8 @@ type Bindable interface {
 	Unbind()
 }
 
-// Draw renders the quad.
+// Draw renders the quad. The given textures are bound to consecutive
+// texture units, starting at 0. Nil entries leave their unit unbound.
 func (d *SimulationDisplay) Draw(textures ...Bindable) {
 	d.shader.Use()
 
@@ -155,8 +211,10 @@ func (d *SimulationDisplay) Draw(textures ...Bindable) {
 	}
 
 	for i, tex := range textures {
-		gl.ActiveTexture(gl.TEXTURE0 + uint32(i))
-		tex.Bind()
+		if tex != nil {
+			gl.ActiveTexture(gl.TEXTURE0 + uint32(i))
+			tex.Bind()
+		}
 	}
 
 	gl.BindVertexArray(d.vao)
@@ -164,9 +222,12 @@ func (d *SimulationDisplay) Draw(textures ...Bindable) {
 	gl.BindVertexArray(0)
 
 	for i, tex := range textures {
-		gl.ActiveTexture(gl.TEXTURE0 + uint32(i))
-		tex.Unbind()
+		if tex != nil {
+			gl.ActiveTexture(gl.TEXTURE0 + uint32(i))
+			tex.Unbind()
+		}
 	}
+	gl.ActiveTexture(gl.TEXTURE0)
 
 	d.shader.Unuse()
 }
@@ -3,6 +3,7 @@ package main
 import (
 	"errors"
 	"fmt"
+	"image"
 
 	"github.com/go-gl/gl/v4.2-core/gl"
 	"github.com/hexaflex/wireworld-gpu/math"
@@ -87,6 +88,29 @@ func (ss *SimulationState) UnbindBuffer() {
 	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
 }
 
+// AttachHeatmap attaches the given heatmap texture to the framebuffer,
+// as a second color buffer. A texture of 0 detaches it. It is only drawn
+// to while the simulation pass sets both draw buffers, see SetDrawBuffers.
+func (ss *SimulationState) AttachHeatmap(tex uint32) {
+	gl.BindFramebuffer(gl.FRAMEBUFFER, ss.fbo)
+	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT1, gl.TEXTURE_2D, tex, 0)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+}
+
+// SetDrawBuffers selects the color buffers drawn to: only the cell states,
+// or the attached heatmap as well. The heatmap is left out by default, so
+// copies and clears do not touch it.
+func (ss *SimulationState) SetDrawBuffers(heatmap bool) {
+	bufs := []uint32{gl.COLOR_ATTACHMENT0, gl.COLOR_ATTACHMENT1}
+	if !heatmap {
+		bufs = bufs[:1]
+	}
+
+	gl.BindFramebuffer(gl.FRAMEBUFFER, ss.fbo)
+	gl.DrawBuffers(int32(len(bufs)), &bufs[0])
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+}
+
 // SetData writes the given state data into the framebuffer's color buffer.
 // Sets the framebuffer dimensions to the given size.
 func (ss *SimulationState) SetData(pix []byte, size math.Vec2) {
@@ -98,6 +122,46 @@ func (ss *SimulationState) SetData(pix []byte, size math.Vec2) {
 	gl.BindTexture(gl.TEXTURE_2D, 0)
 }
 
+// CopyFrom copies the contents of src into the framebuffer's color buffer.
+// Both framebuffers must have the same dimensions. The copy happens on the
+// GPU and does not stall the rendering pipeline.
+func (ss *SimulationState) CopyFrom(src *SimulationState) {
+	ss.CopyRegion(src, image.Rect(0, 0, int(ss.size[0]), int(ss.size[1])), image.Point{})
+}
+
+// CopyRegion copies the cells in region r of src into this framebuffer,
+// with the top-left corner of r placed at dst. The regions must lie within
+// both framebuffers.
+func (ss *SimulationState) CopyRegion(src *SimulationState, r image.Rectangle, dst image.Point) {
+	d := r.Add(dst.Sub(r.Min))
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, src.fbo)
+	gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, ss.fbo)
+	gl.BlitFramebuffer(
+		int32(r.Min.X), int32(r.Min.Y), int32(r.Max.X), int32(r.Max.Y),
+		int32(d.Min.X), int32(d.Min.Y), int32(d.Max.X), int32(d.Max.Y),
+		gl.COLOR_BUFFER_BIT, gl.NEAREST)
+	gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, 0)
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, 0)
+}
+
+// Clear sets all cells to the empty state.
+func (ss *SimulationState) Clear() {
+	gl.BindFramebuffer(gl.FRAMEBUFFER, ss.fbo)
+	gl.ClearColor(0, 0, 0, 0)
+	gl.Clear(gl.COLOR_BUFFER_BIT)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+}
+
+// SetRegion writes the given state data into a rectangular region of the
+// framebuffer's color buffer. The region is given in cells, with (0, 0)
+// being the top-left corner, and must lie within the buffer.
+func (ss *SimulationState) SetRegion(x, y, w, h int, pix []byte) {
+	gl.BindTexture(gl.TEXTURE_2D, ss.tex)
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+	gl.TexSubImage2D(gl.TEXTURE_2D, 0, int32(x), int32(y), int32(w), int32(h), gl.RED, gl.UNSIGNED_BYTE, gl.Ptr(pix))
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+}
+
 // Data reads state state from the framebuffer's color buffer.
 // This uses glReadPixels and is therefore rather slow, so use with care.
 func (ss *SimulationState) Data() []byte {
@@ -108,6 +172,20 @@ func (ss *SimulationState) Data() []byte {
 	return p
 }
 
+// ReadAsync starts an asynchronous read of the state data from the
+// framebuffer's color buffer. Unlike Data, this does not stall the
+// rendering pipeline.
+func (ss *SimulationState) ReadAsync() *Readback {
+	return ss.ReadRegionAsync(0, 0, int(ss.size[0]), int(ss.size[1]))
+}
+
+// ReadRegionAsync is like ReadAsync, but reads only the given region.
+// The region is given in cells, like in SetRegion, and must lie within
+// the buffer.
+func (ss *SimulationState) ReadRegionAsync(x, y, w, h int) *Readback {
+	return newReadback(ss.fbo, int32(x), int32(y), int32(w), int32(h), gl.RED, gl.UNSIGNED_BYTE, 1)
+}
+
 func (ss *SimulationState) checkStatus() error {
 	status := gl.CheckFramebufferStatus(gl.FRAMEBUFFER)
 
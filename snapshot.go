@@ -0,0 +1,62 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+
+	"github.com/hexaflex/wireworld-gpu/math"
+)
+
+// MaxAnnotationColors defines the maximum number of distinct annotation
+// colors retained from an input image. An indexed image holds at most
+// 256 colors, four of which are taken by the cell states.
+const MaxAnnotationColors = 256 - 4
+
+// Annotations holds the pixels of an input image which do not match any of
+// the palette colors. The simulation treats these as empty cells, but they
+// are kept around so snapshots can draw them back in.
+type Annotations struct {
+	Colors color.Palette // Distinct annotation colors.
+	Pix    []byte        // Per-pixel index into Colors, plus one. 0 means no annotation.
+	Size   math.Vec2     // Dimensions of Pix.
+}
+
+// At returns the annotation color index, plus one, at the given position.
+// Returns 0 if there is no annotation there.
+func (a *Annotations) At(x, y int) byte {
+	w, h := int(a.Size[0]), int(a.Size[1])
+	if x < 0 || y < 0 || x >= w || y >= h {
+		return 0
+	}
+	return a.Pix[y*w+x]
+}
+
+// Crop returns a copy of the annotations in the given rectangle.
+// Parts of r outside the annotations are left blank.
+func (a *Annotations) Crop(r image.Rectangle) *Annotations {
+	out := &Annotations{
+		Colors: a.Colors,
+		Pix:    make([]byte, r.Dx()*r.Dy()),
+		Size:   math.Vec2{float32(r.Dx()), float32(r.Dy())},
+	}
+
+	for y := 0; y < r.Dy(); y++ {
+		for x := 0; x < r.Dx(); x++ {
+			out.Pix[y*r.Dx()+x] = a.At(r.Min.X+x, r.Min.Y+y)
+		}
+	}
+
+	return out
+}
+
+// writeSnapshot encodes the given indexed image as a PNG file.
+//
+// The PNG encoder picks the smallest bit depth which fits the image
+// palette. An image without annotations has only the four cell colors
+// and is therefore written at 2 bits per pixel.
+func writeSnapshot(w io.Writer, img *image.Paletted) error {
+	enc := png.Encoder{CompressionLevel: png.BestCompression}
+	return enc.Encode(w, img)
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Population holds the number of cells in each of the non-empty states.
+type Population struct {
+	Wire int
+	Head int
+	Tail int
+}
+
+// Population counts the cells in each state.
+func (g *Grid) Population() Population {
+	var p Population
+	for _, cell := range g.Pix {
+		switch cell {
+		case CellWire:
+			p.Wire++
+		case CellHead:
+			p.Head++
+		case CellTail:
+			p.Tail++
+		}
+	}
+	return p
+}
+
+// Census counts the cells in each state, in the whole grid and in each of
+// the given regions. The whole grid comes first.
+func (g *Grid) Census(regions []Region) []Population {
+	out := make([]Population, 1+len(regions))
+	out[0] = g.Population()
+	for i, r := range regions {
+		out[i+1] = g.Crop(r.Rect.Intersect(g.Bounds())).Population()
+	}
+	return out
+}
+
+// PopulationSeries holds the populations of a circuit and a set of regions
+// in it, for a range of consecutive generations.
+type PopulationSeries struct {
+	window
+	Regions []Region     // Counted regions.
+	Counts  []Population // One row per generation, as returned by Grid.Census.
+}
+
+// NewPopulationSeries creates an empty series for the given regions. It
+// keeps at most limit generations, dropping the oldest ones. A limit of 0
+// means there is no limit.
+func NewPopulationSeries(regions []Region, limit int) *PopulationSeries {
+	return &PopulationSeries{
+		window:  window{width: 1 + len(regions), limit: limit},
+		Regions: regions,
+	}
+}
+
+// At returns the populations in the given generation.
+// Returns nil if the generation has not been recorded.
+func (s *PopulationSeries) At(gen uint64) []Population {
+	lo, hi, ok := s.row(gen)
+	if !ok {
+		return nil
+	}
+	return s.Counts[lo:hi]
+}
+
+// Append adds the rows of consecutive generations, starting at gen.
+// Existing rows for gen and later are replaced, as happens when the
+// simulation is rewound. If there is a gap between the last row and gen,
+// the series starts over at gen.
+func (s *PopulationSeries) Append(gen uint64, counts []Population) {
+	keep, add, drop := s.append(gen, len(counts))
+	s.Counts = append(s.Counts[:keep], counts[:add]...)
+	if drop > 0 {
+		s.Counts = append(s.Counts[:0], s.Counts[drop:]...)
+	}
+}
+
+// WriteCSV writes the series to w as comma separated values. The first
+// line names the columns: the generation, followed by the wire, head and
+// tail counts of the whole circuit, and then those of each region.
+func (s *PopulationSeries) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+
+	header := []string{"generation", "wire", "head", "tail"}
+	for _, r := range s.Regions {
+		header = append(header, r.Name+".wire", r.Name+".head", r.Name+".tail")
+	}
+
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	record := make([]string, len(header))
+	for gen := s.Start; gen < s.End(); gen++ {
+		record = record[:0]
+		record = append(record, strconv.FormatUint(gen, 10))
+		for _, p := range s.At(gen) {
+			record = append(record, strconv.Itoa(p.Wire), strconv.Itoa(p.Head), strconv.Itoa(p.Tail))
+		}
+
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+var statsCommand = &Command{
+	Name:  "stats",
+	Usage: "<file>",
+	Brief: "Prints the dimensions and cell counts of a circuit, or writes them as a CSV time series.",
+	Run:   runStats,
+}
+
+func runStats(cmd *Command, args []string) error {
+	var pal Palette
+	pal.LoadDefault()
+
+	fs := newFlagSet(cmd)
+	paletteFlags(fs, &pal)
+	output := fs.String("o", StdStream, "Output file, or - for stdout.")
+	steps := fs.Int("steps", 0, "Number of generations to simulate before counting.")
+	regionFile := fs.String("regions", "", "Region file. Defaults to the input file name with "+RegionExt+" appended, if it exists.")
+	series := fs.Bool("csv", false, "Write the counts of every generation up to -steps as CSV.")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("missing input file")
+	}
+
+	if *steps < 0 {
+		return errors.New("steps must be >= 0")
+	}
+
+	regions, err := loadRegionsFor(fs.Arg(0), *regionFile)
+	if err != nil {
+		return err
+	}
+
+	g, err := LoadGrid(fs.Arg(0), &pal)
+	if err != nil {
+		return err
+	}
+
+	if *series {
+		s := NewPopulationSeries(regions, 0)
+		for gen := 0; ; gen++ {
+			s.Append(uint64(gen), g.Census(regions))
+			if gen == *steps {
+				break
+			}
+			g.Step(1)
+		}
+
+		w, err := createOutput(*output)
+		if err != nil {
+			return err
+		}
+
+		if err = s.WriteCSV(w); err != nil {
+			w.Close()
+			return err
+		}
+		return w.Close()
+	}
+
+	g.Step(*steps)
+	counts := g.Census(regions)
+	p := counts[0]
+
+	w, err := createOutput(*output)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "size: %dx%d\n", g.Width, g.Height)
+	fmt.Fprintf(w, "generation: %d\n", *steps)
+	fmt.Fprintf(w, "wire: %d\n", p.Wire)
+	fmt.Fprintf(w, "head: %d\n", p.Head)
+	fmt.Fprintf(w, "tail: %d\n", p.Tail)
+
+	for i, r := range regions {
+		p := counts[i+1]
+		fmt.Fprintf(w, "%s: wire %d, head %d, tail %d\n", r.Name, p.Wire, p.Head, p.Tail)
+	}
+	return w.Close()
+}
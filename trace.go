@@ -0,0 +1,41 @@
+package main
+
+// Trace holds the recorded states of a set of probes, for a range of
+// consecutive generations.
+type Trace struct {
+	window
+	Probes  []Probe // Recorded probes.
+	Samples []byte  // Cell states, one row of len(Probes) per generation.
+}
+
+// NewTrace creates an empty trace for the given probes. It keeps at most
+// limit generations, dropping the oldest ones. A limit of 0 means there is
+// no limit.
+func NewTrace(probes []Probe, limit int) *Trace {
+	return &Trace{
+		window: window{width: len(probes), limit: limit},
+		Probes: probes,
+	}
+}
+
+// Sample returns the probe states for the given generation.
+// Returns nil if the generation has not been recorded.
+func (t *Trace) Sample(gen uint64) []byte {
+	lo, hi, ok := t.row(gen)
+	if !ok {
+		return nil
+	}
+	return t.Samples[lo:hi]
+}
+
+// Append adds the samples of consecutive generations, starting at gen.
+// Existing samples for gen and later are replaced, as happens when the
+// simulation is rewound. If there is a gap between the last sample and
+// gen, the trace starts over at gen.
+func (t *Trace) Append(gen uint64, samples []byte) {
+	keep, add, drop := t.append(gen, len(samples))
+	t.Samples = append(t.Samples[:keep], samples[:add]...)
+	if drop > 0 {
+		t.Samples = append(t.Samples[:0], t.Samples[drop:]...)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// writeVCD writes the trace as a Value Change Dump, as read by waveform
+// viewers like GTKWave. Each probe becomes a single bit wire in a module
+// with the given name. One generation takes one nanosecond.
+//
+// Electron heads are written as 1, wire and tail cells as 0. A probe on an
+// empty cell has the value z.
+func writeVCD(w io.Writer, t *Trace, module string) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "$date\n\t%s\n$end\n", time.Now().Format(time.RFC1123))
+	fmt.Fprintf(bw, "$version\n\t%s\n$end\n", Version())
+	fmt.Fprintf(bw, "$timescale 1ns $end\n")
+	fmt.Fprintf(bw, "$scope module %s $end\n", module)
+
+	ids := make([]string, len(t.Probes))
+	for i, p := range t.Probes {
+		ids[i] = vcdIdentifier(i)
+		fmt.Fprintf(bw, "$var wire 1 %s %s $end\n", ids[i], p.Name)
+	}
+
+	fmt.Fprintf(bw, "$upscope $end\n$enddefinitions $end\n")
+
+	var last []byte
+	for gen := t.Start; gen < t.End(); gen++ {
+		sample := t.Sample(gen)
+
+		if last == nil {
+			fmt.Fprintf(bw, "#%d\n$dumpvars\n", gen)
+			for i, cell := range sample {
+				fmt.Fprintf(bw, "%c%s\n", vcdValue(cell), ids[i])
+			}
+			fmt.Fprintf(bw, "$end\n")
+			last = make([]byte, len(sample))
+		} else {
+			stamped := false
+			for i, cell := range sample {
+				if vcdValue(cell) == vcdValue(last[i]) {
+					continue
+				}
+				if !stamped {
+					fmt.Fprintf(bw, "#%d\n", gen)
+					stamped = true
+				}
+				fmt.Fprintf(bw, "%c%s\n", vcdValue(cell), ids[i])
+			}
+		}
+
+		copy(last, sample)
+	}
+
+	// Mark the end of the recording, so viewers show the last generation.
+	if t.Len() > 0 {
+		fmt.Fprintf(bw, "#%d\n", t.End())
+	}
+
+	return bw.Flush()
+}
+
+// vcdValue returns the VCD value of a cell state.
+func vcdValue(cell byte) byte {
+	switch cell {
+	case CellHead:
+		return '1'
+	case CellEmpty:
+		return 'z'
+	default:
+		return '0'
+	}
+}
+
+// vcdIdentifier returns the short identifier code for the n'th variable.
+// These are made up of the printable ASCII characters.
+func vcdIdentifier(n int) string {
+	const first, count = '!', '~' - '!' + 1
+
+	var id []byte
+	for {
+		id = append(id, byte(first+n%count))
+		n /= count
+		if n == 0 {
+			break
+		}
+		n--
+	}
+
+	return string(id)
+}
+
+// circuitName returns the name of the given circuit file, without
+// directory and extension, for use as an identifier. Input read from
+// stdin is named "stdin".
+func circuitName(file string) string {
+	if file == StdStream {
+		return "stdin"
+	}
+
+	name := filepath.Base(file)
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, name)
+}
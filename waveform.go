@@ -0,0 +1,186 @@
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/go-gl/gl/v4.2-core/gl"
+	"github.com/hexaflex/wireworld-gpu/math"
+)
+
+// Colors of the waveform panel.
+var (
+	waveformBackground = color.RGBA{0x18, 0x18, 0x18, 0xe8}
+	waveformSeparator  = color.RGBA{0x40, 0x40, 0x40, 0xff}
+	waveformCursor     = color.RGBA{0xff, 0xc0, 0x20, 0xff}
+)
+
+// Waveform draws a timing diagram of the probed cells at the bottom of the
+// window, in a viewport of its own. Each probe gets a lane, which shows its
+// electron head pulses over the most recent generations. A cursor marks the
+// current generation.
+type Waveform struct {
+	shader  Shader
+	verts   []float32
+	length  int // Number of generations shown.
+	lane    int // Height of a lane in pixels.
+	visible bool
+	vao     uint32
+	vbo     uint32
+}
+
+// NewWaveform creates a hidden waveform panel which shows the given
+// number of generations, in lanes of the given height in pixels.
+func NewWaveform(shader Shader, length, lane int) *Waveform {
+	var w Waveform
+	w.shader = shader
+	w.length = length
+	w.lane = lane
+
+	gl.GenVertexArrays(1, &w.vao)
+	gl.BindVertexArray(w.vao)
+
+	gl.GenBuffers(1, &w.vbo)
+	gl.BindBuffer(gl.ARRAY_BUFFER, w.vbo)
+	gl.EnableVertexAttribArray(0)
+	gl.EnableVertexAttribArray(1)
+	gl.VertexAttribPointer(0, 2, gl.FLOAT, false, 6*4, gl.PtrOffset(0))
+	gl.VertexAttribPointer(1, 4, gl.FLOAT, false, 6*4, gl.PtrOffset(2*4))
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
+	gl.BindVertexArray(0)
+	return &w
+}
+
+// Release cleans up resources.
+func (w *Waveform) Release() {
+	gl.DeleteBuffers(1, &w.vbo)
+	gl.DeleteVertexArrays(1, &w.vao)
+}
+
+// Visible returns true if the panel is shown.
+func (w *Waveform) Visible() bool {
+	return w.visible
+}
+
+// SetVisible shows or hides the panel.
+func (w *Waveform) SetVisible(visible bool) {
+	w.visible = visible
+}
+
+// Height returns the height of the panel in pixels, for the given number
+// of lanes and view size. It takes up at most half the view.
+func (w *Waveform) Height(lanes int, view image.Point) int {
+	h := lanes * w.lane
+	if h > view.Y/2 {
+		h = view.Y / 2
+	}
+	return h
+}
+
+// LaneAt returns the lane at the given screen position.
+// Returns -1 if the panel is hidden or the position lies outside it.
+func (w *Waveform) LaneAt(pos math.Vec2, lanes int, view image.Point) int {
+	h := w.Height(lanes, view)
+	if !w.visible || h == 0 {
+		return -1
+	}
+
+	y := int(pos[1]) - (view.Y - h)
+	if y < 0 || y >= h || pos[0] < 0 || int(pos[0]) >= view.X {
+		return -1
+	}
+
+	return y * lanes / h
+}
+
+// Draw renders the panel along the bottom of a view of the given size,
+// if it is visible. It shows the generations in the trace up to the given
+// current generation, or up to the last recorded one if that is later.
+func (w *Waveform) Draw(t *Trace, generation uint64, view image.Point, pal *Palette) {
+	lanes := len(t.Probes)
+	h := w.Height(lanes, view)
+	if !w.visible || h == 0 {
+		return
+	}
+
+	end := generation + 1
+	if t.End() > end {
+		end = t.End()
+	}
+
+	start := uint64(0)
+	if end > uint64(w.length) {
+		start = end - uint64(w.length)
+	}
+
+	// Size of a screen pixel in waveform units.
+	n := float32(w.length)
+	px := n / float32(view.X)
+	py := float32(lanes) / float32(h)
+
+	w.verts = w.verts[:0]
+	w.quad(0, 0, n, float32(lanes), waveformBackground)
+
+	for i := 0; i < lanes; i++ {
+		y := float32(i)
+		if i > 0 {
+			w.quad(0, y, n, y+py, waveformSeparator)
+		}
+
+		for gen := start; gen < end; gen++ {
+			s := t.Sample(gen)
+			if s == nil {
+				continue
+			}
+
+			x := float32(gen - start)
+			switch s[i] {
+			case CellHead:
+				w.quad(x, y+0.15, x+1, y+0.85, pal.Head)
+			case CellEmpty:
+			default:
+				w.quad(x, y+0.85-py, x+1, y+0.85, pal.Wire)
+			}
+		}
+	}
+
+	// The cursor sits on the current generation. It is only away from
+	// the right edge after stepping back.
+	if generation >= start {
+		x := float32(generation-start) + 0.5
+		w.quad(x-px, 0, x+px, float32(lanes), waveformCursor)
+	}
+
+	gl.Viewport(0, 0, int32(view.X), int32(h))
+
+	w.shader.Use()
+	w.shader.SetUniformMat4("Projection", math.Ortho2D(0, n, float32(lanes), 0))
+
+	gl.BindVertexArray(w.vao)
+	gl.BindBuffer(gl.ARRAY_BUFFER, w.vbo)
+	gl.BufferData(gl.ARRAY_BUFFER, len(w.verts)*4, gl.Ptr(w.verts), gl.STREAM_DRAW)
+	gl.DrawArrays(gl.TRIANGLES, 0, int32(len(w.verts)/6))
+	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
+	gl.BindVertexArray(0)
+
+	w.shader.Unuse()
+	gl.Viewport(0, 0, int32(view.X), int32(view.Y))
+}
+
+// quad adds a rectangle with the given corners and color.
+func (w *Waveform) quad(x0, y0, x1, y1 float32, c color.RGBA) {
+	r := float32(c.R) / 255
+	g := float32(c.G) / 255
+	b := float32(c.B) / 255
+	a := float32(c.A) / 255
+
+	w.verts = append(w.verts,
+		x0, y0, r, g, b, a,
+		x1, y0, r, g, b, a,
+		x0, y1, r, g, b, a,
+		x1, y0, r, g, b, a,
+		x1, y1, r, g, b, a,
+		x0, y1, r, g, b, a,
+	)
+}
@@ -0,0 +1,64 @@
+package main
+
+// window keeps track of the generations held by a series, which stores a
+// fixed number of values per generation in a slice of its own. The window
+// decides which rows an append replaces and which ones fall out of the
+// limit. The series moves the values accordingly.
+type window struct {
+	Start uint64 // Generation of the first row.
+	rows  int    // Number of recorded generations.
+	width int    // Values per generation.
+	limit int    // Maximum number of generations to keep, or 0 for no limit.
+}
+
+// Len returns the number of recorded generations.
+func (w *window) Len() int {
+	return w.rows
+}
+
+// End returns the generation after the last row.
+func (w *window) End() uint64 {
+	return w.Start + uint64(w.rows)
+}
+
+// row returns the bounds of the values of the given generation in the
+// series' slice. Returns false if the generation has not been recorded.
+func (w *window) row(gen uint64) (int, int, bool) {
+	if gen < w.Start || gen >= w.End() {
+		return 0, 0, false
+	}
+	i := int(gen-w.Start) * w.width
+	return i, i + w.width, true
+}
+
+// append records the addition of n values of consecutive generations,
+// starting at gen. Existing rows for gen and later are replaced, as happens
+// when the simulation is rewound. If there is a gap between the last row and
+// gen, the window starts over at gen. Incomplete rows are ignored.
+//
+// The series keeps the first keep values it has, appends the first add of
+// the new ones and then drops the first drop values of the result.
+func (w *window) append(gen uint64, n int) (keep, add, drop int) {
+	if w.width == 0 || n < w.width {
+		return w.rows * w.width, 0, 0
+	}
+
+	switch {
+	case w.rows == 0 || gen < w.Start || gen > w.End():
+		w.Start = gen
+		w.rows = 0
+	case gen < w.End():
+		w.rows = int(gen - w.Start)
+	}
+
+	keep = w.rows * w.width
+	w.rows += n / w.width
+
+	if w.limit > 0 && w.rows > w.limit {
+		drop = w.rows - w.limit
+		w.rows = w.limit
+		w.Start += uint64(drop)
+	}
+
+	return keep, n / w.width * w.width, drop * w.width
+}
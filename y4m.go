@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+// Y4MWriter writes frames as an uncompressed YUV4MPEG2 stream. This format
+// is understood by most video encoders, like ffmpeg and x264. Frames use
+// full range 4:4:4 sampling, so no color information is lost on the cell
+// boundaries.
+type Y4MWriter struct {
+	w      *bufio.Writer
+	width  int
+	height int
+	fps    int
+	header bool
+}
+
+// NewY4MWriter creates a new stream of frames with the given dimensions
+// and frame rate.
+func NewY4MWriter(w io.Writer, width, height, fps int) *Y4MWriter {
+	return &Y4MWriter{
+		w:      bufio.NewWriter(w),
+		width:  width,
+		height: height,
+		fps:    fps,
+	}
+}
+
+// WriteFrame appends img to the stream. It must have the stream dimensions.
+func (y *Y4MWriter) WriteFrame(img *image.Paletted) error {
+	b := img.Bounds()
+	if b.Dx() != y.width || b.Dy() != y.height {
+		return fmt.Errorf("y4m: frame size %dx%d does not match stream size %dx%d",
+			b.Dx(), b.Dy(), y.width, y.height)
+	}
+
+	if !y.header {
+		y.header = true
+		_, err := fmt.Fprintf(y.w, "YUV4MPEG2 W%d H%d F%d:1 Ip A1:1 C444 XCOLORRANGE=FULL\n",
+			y.width, y.height, y.fps)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Convert the palette once, instead of every pixel.
+	yuv := make([][3]byte, len(img.Palette))
+	for i, c := range img.Palette {
+		rgba := color.RGBAModel.Convert(c).(color.RGBA)
+		yy, cb, cr := color.RGBToYCbCr(rgba.R, rgba.G, rgba.B)
+		yuv[i] = [3]byte{yy, cb, cr}
+	}
+
+	if _, err := io.WriteString(y.w, "FRAME\n"); err != nil {
+		return err
+	}
+
+	for plane := 0; plane < 3; plane++ {
+		for py := 0; py < y.height; py++ {
+			row := img.Pix[py*img.Stride : py*img.Stride+y.width]
+			for _, index := range row {
+				if err := y.w.WriteByte(yuv[index][plane]); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (y *Y4MWriter) Flush() error {
+	return y.w.Flush()
+}